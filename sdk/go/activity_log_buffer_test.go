@@ -0,0 +1,168 @@
+package sdk_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+// logEngine records the activity log chunks uploaded to it.
+type logEngine struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	chunks []string
+}
+
+func newLogEngine() *logEngine {
+	e := &logEngine{}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("content")
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		chunk, _ := io.ReadAll(file)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.chunks = append(e.chunks, string(chunk))
+	}))
+	return e
+}
+
+func (e *logEngine) uploaded() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.chunks...)
+}
+
+func TestActivityLogBuffer(t *testing.T) {
+	newWriter := func(engine *logEngine, diagnostics io.Writer, flushRate time.Duration, size int, policy sdk.BufferPolicy) io.WriteCloser {
+		logger := slog.New(slog.NewTextHandler(diagnostics, nil))
+		return sdk.NewActivityLogWriter(context.Background(), logger, engine.URL+"/logs", "token",
+			sdk.WithLogReqTimeout(time.Second),
+			sdk.WithWriteFlushTickRate(flushRate),
+			sdk.WithMaxBufferSize(size, policy),
+		)
+	}
+
+	t.Run("drop oldest", func(t *testing.T) {
+		engine := newLogEngine()
+		defer engine.Close()
+		var diagnostics bytes.Buffer
+
+		w := newWriter(engine, &diagnostics, time.Hour, 16, sdk.BufferPolicyDropOldest)
+		for _, line := range []string{"line one\n", "line two\n", "line three\n"} {
+			if _, err := io.WriteString(w, line); err != nil {
+				t.Fatalf("Error writing: %v", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Error closing writer: %v", err)
+		}
+
+		want := "[18 bytes dropped]\nline three\n"
+		if got := strings.Join(engine.uploaded(), ""); got != want {
+			t.Errorf("Expected upload %q, got %q", want, got)
+		}
+		if !strings.Contains(diagnostics.String(), "droppedBytes=18") {
+			t.Errorf("Expected dropped bytes in diagnostics:\n%s", diagnostics.String())
+		}
+	})
+
+	t.Run("flush early", func(t *testing.T) {
+		engine := newLogEngine()
+		defer engine.Close()
+		var diagnostics bytes.Buffer
+
+		w := newWriter(engine, &diagnostics, time.Hour, 16, sdk.BufferPolicyFlushEarly)
+		_, _ = io.WriteString(w, "line one\n")
+		time.Sleep(50 * time.Millisecond)
+		if got := engine.uploaded(); len(got) != 0 {
+			t.Fatalf("Expected no upload before the buffer is full, got %q", got)
+		}
+		_, _ = io.WriteString(w, "line two\n")
+		for deadline := time.Now().Add(2 * time.Second); len(engine.uploaded()) == 0 && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := engine.uploaded(); len(got) != 1 || got[0] != "line one\nline two\n" {
+			t.Fatalf("Expected the full buffer to be uploaded before the next tick, got %q", got)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Error closing writer: %v", err)
+		}
+		if !strings.Contains(diagnostics.String(), "earlyFlushes=1") {
+			t.Errorf("Expected early flushes in diagnostics:\n%s", diagnostics.String())
+		}
+	})
+
+	t.Run("flush early does not wait for the upload", func(t *testing.T) {
+		release := make(chan struct{})
+		engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer engine.Close()
+
+		w := sdk.NewActivityLogWriter(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), engine.URL+"/logs", "token",
+			sdk.WithLogReqTimeout(5*time.Second),
+			sdk.WithWriteFlushTickRate(time.Hour),
+			sdk.WithMaxBufferSize(16, sdk.BufferPolicyFlushEarly),
+		)
+		start := time.Now()
+		for _, line := range []string{"line one\n", "line two\n", "line three\n"} {
+			_, _ = io.WriteString(w, line)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected writes not to wait for the upload, took %v", elapsed)
+		}
+		close(release)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Error closing writer: %v", err)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		engine := newLogEngine()
+		defer engine.Close()
+		var diagnostics bytes.Buffer
+
+		w := newWriter(engine, &diagnostics, 50*time.Millisecond, 16, sdk.BufferPolicyBlock)
+		_, _ = io.WriteString(w, "line one\n")
+		start := time.Now()
+		_, _ = io.WriteString(w, "line two\n")
+		// the second write waited for a tick to take the first line out of the buffer
+		if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+			t.Errorf("Expected the second write to block until the next flush, returned after %v", elapsed)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Error closing writer: %v", err)
+		}
+		if got := strings.Join(engine.uploaded(), ""); got != "line one\nline two\n" {
+			t.Errorf("Expected both lines in order, got %q", got)
+		}
+		if !strings.Contains(diagnostics.String(), "blockedWrites=1") {
+			t.Errorf("Expected blocked writes in diagnostics:\n%s", diagnostics.String())
+		}
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		_, err := sdk.NewFunctionSDK(
+			sdk.WithLogBufferPolicy("spill"),
+			sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+				return sdk.Response{}, nil
+			}),
+		)
+		if err == nil {
+			t.Errorf("Expected unknown buffer policy to fail")
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package sdk_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestActivityLogCompression(t *testing.T) {
+	invoke := func(t *testing.T, acceptGzip, rejectGzip bool, opts ...sdk.SDKOption) (logs string, encodings []string) {
+		var mu sync.Mutex
+		engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := r.Header.Get("Content-Encoding")
+			if encoding == "gzip" {
+				if rejectGzip {
+					w.WriteHeader(http.StatusUnsupportedMediaType)
+					return
+				}
+				body, err := gzip.NewReader(r.Body)
+				if err != nil {
+					t.Errorf("Error reading gzip body: %v", err)
+					return
+				}
+				r.Body = io.NopCloser(body)
+			}
+			file, _, err := r.FormFile("content")
+			if err != nil {
+				return
+			}
+			defer file.Close()
+			chunk, _ := io.ReadAll(file)
+			mu.Lock()
+			defer mu.Unlock()
+			logs += string(chunk)
+			encodings = append(encodings, encoding)
+		}))
+		defer engine.Close()
+
+		opts = append(opts,
+			sdk.WithLogWriteTimeout(time.Second),
+			sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+				logger.Info("terraform plan", "resources", 42)
+				return sdk.Response{}, nil
+			}),
+		)
+		funcSDK, err := sdk.NewFunctionSDK(opts...)
+		if err != nil {
+			t.Fatalf("Error creating function SDK: %v", err)
+		}
+		server := httptest.NewServer(funcSDK.Handler())
+		defer server.Close()
+
+		req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		req.Header.Set(sdk.EngineAPIEndpointHeader, engine.URL)
+		req.Header.Set(sdk.ActivityFileUploadHeader, "/logs")
+		if acceptGzip {
+			req.Header.Set(sdk.ActivityLogEncodingHeader, "br, gzip")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		resp.Body.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+		return logs, encodings
+	}
+
+	for _, tc := range []struct {
+		name         string
+		acceptGzip   bool
+		rejectGzip   bool
+		opts         []sdk.SDKOption
+		wantEncoding string
+	}{
+		{name: "negotiated", acceptGzip: true, wantEncoding: "gzip"},
+		{name: "older engine", acceptGzip: false, wantEncoding: ""},
+		{name: "disabled", acceptGzip: true, opts: []sdk.SDKOption{sdk.WithLogCompression(false)}, wantEncoding: ""},
+		{name: "rejected", acceptGzip: true, rejectGzip: true, wantEncoding: ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logs, encodings := invoke(t, tc.acceptGzip, tc.rejectGzip, tc.opts...)
+			if !strings.Contains(logs, `msg="terraform plan"`) || !strings.Contains(logs, "resources=42") {
+				t.Errorf("Unexpected activity log:\n%s", logs)
+			}
+			for _, encoding := range encodings {
+				if encoding != tc.wantEncoding {
+					t.Errorf("Expected upload encoding %q, got %q", tc.wantEncoding, encoding)
+				}
+			}
+		})
+	}
+}
+
+func TestActivityLogFlushThreshold(t *testing.T) {
+	engine := newLogEngine()
+	defer engine.Close()
+
+	w := sdk.NewActivityLogWriter(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), engine.URL+"/logs", "token",
+		sdk.WithLogReqTimeout(time.Second),
+		sdk.WithWriteFlushTickRate(time.Hour),
+		sdk.WithFlushThreshold(8),
+	)
+	defer w.Close()
+
+	_, _ = io.WriteString(w, "short\n")
+	_, _ = io.WriteString(w, "past the threshold\n")
+	deadline := time.Now().Add(2 * time.Second)
+	for len(engine.uploaded()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := strings.Join(engine.uploaded(), ""); got != "short\npast the threshold\n" {
+		t.Errorf("Expected the buffer to be uploaded before the next tick, got %q", got)
+	}
+}
@@ -0,0 +1,296 @@
+package sdk_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestActivityLogSpool(t *testing.T) {
+	var (
+		down   atomic.Bool
+		mu     sync.Mutex
+		chunks []string
+	)
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		file, _, err := r.FormFile("content")
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		chunk, _ := io.ReadAll(file)
+		mu.Lock()
+		defer mu.Unlock()
+		chunks = append(chunks, r.Header.Get(sdk.ActivityLogSequenceHeader)+" "+string(chunk))
+	}))
+	defer engine.Close()
+
+	newWriter := func(spoolDir string) io.WriteCloser {
+		// every write wakes a flush
+		return sdk.NewActivityLogWriter(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), engine.URL+"/logs", "token",
+			sdk.WithLogReqTimeout(time.Second),
+			sdk.WithWriteFlushTickRate(time.Hour),
+			sdk.WithMaxBufferSize(1, sdk.BufferPolicyFlushEarly),
+			sdk.WithSpoolDir(spoolDir),
+		)
+	}
+
+	t.Run("retried in order", func(t *testing.T) {
+		mu.Lock()
+		chunks = nil
+		mu.Unlock()
+		spoolDir := filepath.Join(t.TempDir(), "activity1")
+
+		down.Store(true)
+		w := newWriter(spoolDir)
+		for i, line := range []string{"one\n", "two\n"} {
+			_, _ = io.WriteString(w, line)
+			entries, _ := os.ReadDir(spoolDir)
+			for deadline := time.Now().Add(2 * time.Second); len(entries) <= i && time.Now().Before(deadline); {
+				time.Sleep(10 * time.Millisecond)
+				entries, _ = os.ReadDir(spoolDir)
+			}
+			if len(entries) != i+1 {
+				t.Fatalf("Expected %d spooled chunks, got %d", i+1, len(entries))
+			}
+		}
+
+		down.Store(false)
+		_, _ = io.WriteString(w, "three\n")
+		if err := w.Close(); err != nil {
+			t.Fatalf("Error closing writer: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if diff := cmp.Diff([]string{"1 one\n", "2 two\n", "3 three\n"}, chunks); diff != "" {
+			t.Errorf("Uploaded chunks mismatch (-want +got):\n%s", diff)
+		}
+		if _, err := os.Stat(spoolDir); !os.IsNotExist(err) {
+			t.Errorf("Expected spool directory to be removed, got %v", err)
+		}
+	})
+
+	t.Run("kept when the engine stays down", func(t *testing.T) {
+		spoolDir := filepath.Join(t.TempDir(), "activity2")
+
+		down.Store(true)
+		w := newWriter(spoolDir)
+		_, _ = io.WriteString(w, "one\n")
+		if err := w.Close(); err == nil {
+			t.Errorf("Expected close to fail while the engine is down")
+		}
+
+		entries, err := os.ReadDir(spoolDir)
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("Expected the chunk to stay spooled, got %v, %v", entries, err)
+		}
+		chunk, err := os.ReadFile(filepath.Join(spoolDir, entries[0].Name()))
+		if err != nil || string(chunk) != "one\n" {
+			t.Errorf("Expected spooled chunk %q, got %q, %v", "one\n", chunk, err)
+		}
+	})
+}
+
+func TestActivityLogSequence(t *testing.T) {
+	var (
+		down atomic.Bool
+		mu   sync.Mutex
+		seqs []string
+	)
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		seqs = append(seqs, r.Header.Get(sdk.ActivityLogAttemptHeader)+" "+r.Header.Get(sdk.ActivityLogSequenceHeader))
+	}))
+	defer engine.Close()
+
+	spoolDir := t.TempDir()
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithLogWriteTimeout(time.Second),
+		sdk.WithLogSpoolDir(spoolDir),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			logger.Info("applying plan")
+			sdk.Progress(ctx).Report("apply", 50, "applying plan")
+			return sdk.Response{}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+	server := httptest.NewServer(funcSDK.Handler())
+	defer server.Close()
+
+	invoke := func(activityID string) {
+		req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		req.Header.Set(sdk.EngineAPIEndpointHeader, engine.URL)
+		req.Header.Set(sdk.ActivityFileUploadHeader, "/logs")
+		if activityID != "" {
+			req.Header.Set(sdk.ActivityIDHeader, activityID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// attempts maps the attempts to their sequence numbers in the order received
+	attempts := func() map[string][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		byAttempt := make(map[string][]string)
+		for _, s := range seqs {
+			attempt, seq, _ := strings.Cut(s, " ")
+			byAttempt[attempt] = append(byAttempt[attempt], seq)
+		}
+		seqs = nil
+		return byAttempt
+	}
+
+	t.Run("shared by log and progress", func(t *testing.T) {
+		invoke("activity1")
+		for attempt, got := range attempts() {
+			sort.Strings(got)
+			if attempt == "" || !cmp.Equal([]string{"1", "2"}, got) {
+				t.Errorf("Expected sequence 1, 2 of one attempt, got %q: %v", attempt, got)
+			}
+		}
+	})
+
+	t.Run("new attempt on redelivery", func(t *testing.T) {
+		invoke("activity1")
+		first := attempts()
+		invoke("activity1")
+		second := attempts()
+		if len(first) != 1 || len(second) != 1 {
+			t.Fatalf("Expected one attempt per invocation, got %v and %v", first, second)
+		}
+		for attempt, got := range second {
+			if _, ok := first[attempt]; ok {
+				t.Errorf("Expected the redelivery to be a new attempt, got %q again", attempt)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff([]string{"1", "2"}, got); diff != "" {
+				t.Errorf("Sequence numbers mismatch (-want +got):\n%s", diff)
+			}
+		}
+	})
+
+	t.Run("spooled per attempt", func(t *testing.T) {
+		down.Store(true)
+		defer down.Store(false)
+		invoke("activity2")
+		invoke("activity2")
+		attemptDirs, err := os.ReadDir(filepath.Join(spoolDir, "activity2"))
+		if err != nil || len(attemptDirs) != 2 {
+			t.Fatalf("Expected a spool directory per attempt, got %v, %v", attemptDirs, err)
+		}
+		for _, dir := range attemptDirs {
+			if chunks, _ := os.ReadDir(filepath.Join(spoolDir, "activity2", dir.Name())); len(chunks) != 2 {
+				t.Errorf("Expected the log and progress chunks of attempt %s, got %v", dir.Name(), chunks)
+			}
+		}
+		if err := os.RemoveAll(filepath.Join(spoolDir, "activity2")); err != nil {
+			t.Fatalf("Error removing spooled chunks: %v", err)
+		}
+	})
+
+	t.Run("no spool without activity ID", func(t *testing.T) {
+		down.Store(true)
+		defer down.Store(false)
+		invoke("")
+		if _, err := os.Stat(spoolDir); err != nil {
+			t.Fatalf("Expected spool directory to be kept, got %v", err)
+		}
+		if entries, _ := os.ReadDir(spoolDir); len(entries) != 0 {
+			t.Errorf("Expected nothing to be spooled, got %v", entries)
+		}
+	})
+}
+
+func TestActivityLogSpoolExpiry(t *testing.T) {
+	spoolDir := t.TempDir()
+	spool := func(activityID string, age time.Duration) string {
+		dir := filepath.Join(spoolDir, activityID, "attempt1")
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatalf("Error creating spool directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "stdout-00000000000000000001"), []byte("one\n"), 0o600); err != nil {
+			t.Fatalf("Error spooling chunk: %v", err)
+		}
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(dir, modTime, modTime); err != nil {
+			t.Fatalf("Error aging spool directory: %v", err)
+		}
+		return dir
+	}
+	expired := spool("activity1", 2*time.Hour)
+	recent := spool("activity2", 0)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating listener: %v", err)
+	}
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithListener(listener),
+		sdk.WithLogSpoolDir(spoolDir),
+		sdk.WithLogSpoolTTL(time.Hour),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			return sdk.Response{}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- funcSDK.Run(ctx)
+	}()
+
+	gone := func(path string) bool {
+		_, err := os.Stat(path)
+		return os.IsNotExist(err)
+	}
+	for i := 0; i < 50 && !gone(filepath.Dir(expired)); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !gone(filepath.Dir(expired)) {
+		t.Errorf("Expected expired chunks to be removed with their activity directory")
+	}
+	if gone(recent) {
+		t.Errorf("Expected recent chunks to be kept")
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Error running function SDK: %v", err)
+	}
+}
@@ -2,21 +2,70 @@ package sdk
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/RafaySystems/function-templates/sdk/go/pkg/httputil"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/errgroup"
 )
 
+const (
+	// ActivityLogSequenceHeader numbers the chunks uploaded for an attempt of an
+	// activity from 1, across its log and progress files, so that the engine can
+	// skip a chunk it already appended when the upload is retried.
+	ActivityLogSequenceHeader = "X-Activity-Log-Sequence"
+	// ActivityLogAttemptHeader identifies the invocation that uploaded a chunk, so
+	// that the chunks of a redelivered activity, numbered from 1 again, are not
+	// taken for retries of the earlier attempt's chunks.
+	ActivityLogAttemptHeader = "X-Activity-Log-Attempt"
+	// ActivityLogEncodingHeader is sent by engines accepting compressed activity
+	// log uploads, listing the accepted content encodings such as gzip.
+	ActivityLogEncodingHeader = "X-Activity-Log-Encoding"
+)
+
+// BufferPolicy decides what the activity log writer does once its buffer is full.
+type BufferPolicy string
+
+const (
+	// BufferPolicyBlock makes writes wait until the next flush made room.
+	BufferPolicyBlock BufferPolicy = "block"
+	// BufferPolicyDropOldest drops the oldest lines and uploads a
+	// "[N bytes dropped]" marker in their place.
+	BufferPolicyDropOldest BufferPolicy = "drop-oldest"
+	// BufferPolicyFlushEarly wakes the upload of the buffer as soon as a write
+	// filled it, without waiting for the next tick. The write does not wait for
+	// the upload, so the buffer can outgrow its size until the upload takes it.
+	BufferPolicyFlushEarly BufferPolicy = "flush-early"
+)
+
+func (p BufferPolicy) validate() error {
+	switch p {
+	case BufferPolicyBlock, BufferPolicyDropOldest, BufferPolicyFlushEarly:
+		return nil
+	default:
+		return fmt.Errorf("unknown activity log buffer policy %q", p)
+	}
+}
+
 type writer struct {
 	sync.Mutex
+	// flushMu serializes uploads so that chunks reach the engine in order
+	flushMu sync.Mutex
 
 	url           string
 	token         string
@@ -26,8 +75,34 @@ type writer struct {
 	client        *http.Client
 	skipTLSVerify bool
 	reqTimeout    time.Duration
-
-	buf []byte
+	metrics       *metrics
+	formField     string
+	formFileName  string
+
+	maxBufferSize int
+	bufferPolicy  BufferPolicy
+	spoolDir      string
+	// flushThreshold triggers a flush before the next tick once the buffer holds
+	// that many bytes
+	flushThreshold int
+	flushNow       chan struct{}
+
+	buf     []byte
+	dropped int
+	closed  bool
+	space   *sync.Cond
+
+	// diagnostics logged on Close
+	droppedTotal  int
+	blockedWrites int
+	earlyFlushes  int
+
+	seq       *atomic.Uint64
+	attemptID string
+
+	// guarded by flushMu
+	spooled  []uint64
+	compress bool
 
 	stop chan struct{}
 }
@@ -52,26 +127,124 @@ var WithLogReqTimeout = func(reqTimeout time.Duration) WriterOption {
 	}
 }
 
+// WithMaxBufferSize bounds the bytes buffered between uploads, applying policy
+// once the buffer is full. Zero or less means unbounded.
+var WithMaxBufferSize = func(size int, policy BufferPolicy) WriterOption {
+	return func(w *writer) {
+		w.maxBufferSize = size
+		w.bufferPolicy = policy
+	}
+}
+
+// WithSpoolDir persists the chunks that failed to upload in dir and retries them,
+// in order, on later flushes and on Close. dir is created when first needed.
+// Chunks still pending on Close are left in dir and not read again.
+var WithSpoolDir = func(dir string) WriterOption {
+	return func(w *writer) {
+		w.spoolDir = dir
+	}
+}
+
+// WithFlushThreshold flushes the buffer as soon as it holds size bytes instead of
+// waiting for the next tick. Zero or less only flushes on ticks.
+var WithFlushThreshold = func(size int) WriterOption {
+	return func(w *writer) {
+		w.flushThreshold = size
+	}
+}
+
+// WithGzip compresses uploads with gzip. Only enable it for engines that accept
+// it, see ActivityLogEncodingHeader; an engine answering 415 Unsupported Media
+// Type gets plain uploads from then on.
+var WithGzip = func(enabled bool) WriterOption {
+	return func(w *writer) {
+		w.compress = enabled
+	}
+}
+
+// withFormFile uploads the buffered content as the form file filename in field.
+func withFormFile(field, filename string) WriterOption {
+	return func(w *writer) {
+		w.formField = field
+		w.formFileName = filename
+	}
+}
+
+// WithSequence numbers uploads with seq instead of a counter of the writer, so that
+// the writers of an activity share one ActivityLogSequenceHeader sequence.
+var WithSequence = func(seq *atomic.Uint64) WriterOption {
+	return func(w *writer) {
+		w.seq = seq
+	}
+}
+
+// WithAttemptID sends id with every upload in the ActivityLogAttemptHeader. The
+// writers of an invocation share one attempt ID, see NewAttemptID.
+var WithAttemptID = func(id string) WriterOption {
+	return func(w *writer) {
+		w.attemptID = id
+	}
+}
+
+// NewAttemptID returns a new ID for an attempt at an activity. IDs sort in the
+// order the attempts were started.
+func NewAttemptID() string {
+	var suffix [4]byte
+	_, _ = rand.Read(suffix[:])
+	return fmt.Sprintf("%016x%x", time.Now().UnixNano(), suffix)
+}
+
+// withHTTPClient uploads with client instead of a client created by the writer.
+func withHTTPClient(client *http.Client) WriterOption {
+	return func(w *writer) {
+		w.client = client
+	}
+}
+
+// withWriterMetrics records upload bytes and failures in m.
+func withWriterMetrics(m *metrics) WriterOption {
+	return func(w *writer) {
+		w.metrics = m
+	}
+}
+
 func NewActivityLogWriter(ctx context.Context, logger *slog.Logger, url, token string, opts ...WriterOption) io.WriteCloser {
 	w := &writer{
-		ctx:    ctx,
-		logger: logger,
-		url:    url,
-		token:  token,
-		buf:    []byte{},
-		stop:   make(chan struct{}, 1),
+		ctx:          ctx,
+		logger:       logger,
+		url:          url,
+		token:        token,
+		buf:          []byte{},
+		stop:         make(chan struct{}, 1),
+		flushNow:     make(chan struct{}, 1),
+		formField:    "content",
+		formFileName: "stdout",
 	}
 
 	for _, opt := range opts {
 		opt(w)
 	}
 
-	var httpopts []httputil.RetriableHTTPOption
-	if w.skipTLSVerify {
-		httpopts = append(httpopts, httputil.WithTLSInsecureSkipVerify())
+	if w.seq == nil {
+		w.seq = new(atomic.Uint64)
 	}
 
-	w.client = httputil.NewRetriableHTTPClient(httpopts...).StandardClient()
+	w.space = sync.NewCond(&w.Mutex)
+	// blocked writes give up once the invocation's log context is done
+	context.AfterFunc(ctx, func() {
+		w.Lock()
+		w.space.Broadcast()
+		w.Unlock()
+	})
+
+	if w.client == nil {
+		var httpopts []httputil.RetriableHTTPOption
+		if w.skipTLSVerify {
+			httpopts = append(httpopts, httputil.WithTLSInsecureSkipVerify())
+		}
+
+		w.client = httputil.NewRetriableHTTPClient(httpopts...).StandardClient()
+	}
 
 	go w.startUpload()
 	return w
@@ -79,18 +252,99 @@ func NewActivityLogWriter(ctx context.Context, logger *slog.Logger, url, token s
 
 func (w *writer) Close() error {
 	close(w.stop)
-	return w.flush()
+
+	w.Lock()
+	w.closed = true
+	w.space.Broadcast()
+	w.Unlock()
+
+	err := w.flush()
+	w.logDiagnostics()
+	w.closeSpool()
+	return err
+}
+
+// closeSpool removes the spool directory once every chunk was uploaded and keeps
+// it otherwise, so that the missing chunks can still be recovered from disk.
+func (w *writer) closeSpool() {
+	if w.spoolDir == "" {
+		return
+	}
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	if len(w.spooled) > 0 {
+		w.logger.Error("activity log chunks could not be uploaded", "pending", len(w.spooled), "spoolDir", w.spoolDir)
+		return
+	}
+	_ = os.Remove(w.spoolDir)
 }
 
 func (w *writer) Write(b []byte) (n int, err error) {
 	w.Lock()
+	full := w.maxBufferSize > 0 && len(w.buf)+len(b) > w.maxBufferSize
+	if full && w.bufferPolicy == BufferPolicyBlock {
+		w.blockedWrites++
+		// a write larger than the buffer is let through once the buffer is empty
+		for len(w.buf) > 0 && len(w.buf)+len(b) > w.maxBufferSize && !w.closed && w.ctx.Err() == nil {
+			w.space.Wait()
+		}
+	}
 	w.buf = append(w.buf, b...)
+	if w.flushThreshold > 0 && len(w.buf) >= w.flushThreshold {
+		w.requestFlush()
+	}
+	if full && w.bufferPolicy == BufferPolicyDropOldest {
+		w.dropOldest()
+	}
+	if full && w.bufferPolicy == BufferPolicyFlushEarly {
+		w.earlyFlushes++
+		w.requestFlush()
+	}
 	w.Unlock()
 
 	n = len(b)
 	return
 }
 
+// requestFlush wakes the upload goroutine without waiting for it: writes come from
+// log handlers holding their own lock, which must not be held during an upload.
+func (w *writer) requestFlush() {
+	select {
+	case w.flushNow <- struct{}{}:
+	default:
+	}
+}
+
+// dropOldest drops whole lines from the start of the buffer until it fits again.
+func (w *writer) dropOldest() {
+	excess := len(w.buf) - w.maxBufferSize
+	if excess <= 0 {
+		return
+	}
+	cut := excess
+	if i := bytes.IndexByte(w.buf[excess:], '\n'); i >= 0 {
+		cut = excess + i + 1
+	}
+	w.dropped += cut
+	w.droppedTotal += cut
+	w.metrics.logDropped(cut)
+	w.buf = append(w.buf[0:0:0], w.buf[cut:]...)
+}
+
+func (w *writer) logDiagnostics() {
+	w.Lock()
+	defer w.Unlock()
+	if w.droppedTotal == 0 && w.blockedWrites == 0 && w.earlyFlushes == 0 {
+		return
+	}
+	w.logger.Warn("activity log buffer was full",
+		"maxBufferSize", w.maxBufferSize,
+		"policy", w.bufferPolicy,
+		"droppedBytes", w.droppedTotal,
+		"blockedWrites", w.blockedWrites,
+		"earlyFlushes", w.earlyFlushes)
+}
+
 func (w *writer) startUpload() {
 	ticker := time.NewTicker(w.flushTickRate)
 	defer ticker.Stop()
@@ -100,90 +354,196 @@ func (w *writer) startUpload() {
 		case <-w.stop:
 			return
 		case <-ticker.C:
-			err := w.flush()
-			if err != nil {
-				w.logger.Error("error flushing writer", "error", err)
-			}
+		case <-w.flushNow:
+		}
+		if err := w.flush(); err != nil {
+			w.logger.Error("error flushing writer", "error", err)
 		}
 	}
 }
 
-func (w *writer) flush() error {
+func (w *writer) flush() (err error) {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
 	if w.isEmpty() {
 		return nil
 	}
 
-	w.logger.Debug("flushing writer", "buf", string(w.buf))
+	ctx, span := StartSpan(w.ctx, "activity_log.flush")
+	defer func() { EndSpan(span, err) }()
 
 	w.Lock()
-	reader := bytes.NewBuffer(append(w.buf[0:0:0], w.buf...))
+	w.logger.Debug("flushing writer", "buf", string(w.buf))
+	var chunk []byte
+	if w.dropped > 0 {
+		chunk = fmt.Appendf(chunk, "[%d bytes dropped]\n", w.dropped)
+		w.dropped = 0
+	}
+	chunk = append(chunk, w.buf...)
 	w.buf = w.buf[0:0:0]
+	w.space.Broadcast()
 	w.Unlock()
 
-	if reader.Len() > 0 {
-		piper, pipew := io.Pipe()
-		writer := multipart.NewWriter(pipew)
-		defer func() {
-			writer.Close()
-			pipew.Close()
-			piper.Close()
-		}()
-
-		ctx, cancel := context.WithTimeout(w.ctx, w.reqTimeout)
-		defer cancel()
-		group, gctx := errgroup.WithContext(ctx)
-
-		path := w.url + "?append=true"
-		req, err := http.NewRequestWithContext(gctx, "POST", path, piper)
+	span.SetAttributes(attribute.Int("activity_log.bytes", len(chunk)))
+
+	// chunks spooled by earlier flushes go first so that the log stays in order
+	err = w.retrySpooled(ctx)
+	if len(chunk) == 0 {
+		return err
+	}
+	seq := w.seq.Add(1)
+	if err == nil {
+		err = w.upload(ctx, seq, chunk)
+	}
+	if err != nil && w.spoolDir != "" {
+		if spoolErr := w.spool(seq, chunk); spoolErr != nil {
+			return errors.Join(err, spoolErr)
+		}
+	}
+	return err
+}
+
+// upload appends chunk to the activity log file in the engine.
+func (w *writer) upload(ctx context.Context, seq uint64, chunk []byte) error {
+	err := w.send(ctx, seq, chunk, w.compress)
+	if errors.Is(err, errUnsupportedMediaType) && w.compress {
+		w.logger.Info("engine does not accept compressed activity logs, uploading them uncompressed")
+		w.compress = false
+		err = w.send(ctx, seq, chunk, false)
+	}
+	if err != nil {
+		w.metrics.logUploadFailed()
+		return err
+	}
+	w.metrics.logUploaded(len(chunk))
+	return nil
+}
+
+var errUnsupportedMediaType = errors.New("error: engine does not accept the activity log encoding")
+
+func (w *writer) send(ctx context.Context, seq uint64, chunk []byte, compress bool) error {
+	reader := bytes.NewReader(chunk)
+	piper, pipew := io.Pipe()
+	var body io.WriteCloser = pipew
+	if compress {
+		body = gzip.NewWriter(pipew)
+	}
+	writer := multipart.NewWriter(body)
+	defer func() {
+		writer.Close()
+		pipew.Close()
+		piper.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, w.reqTimeout)
+	defer cancel()
+	group, gctx := errgroup.WithContext(ctx)
+
+	path := w.url + "?append=true"
+	req, err := http.NewRequestWithContext(gctx, "POST", path, piper)
+	if err != nil {
+		w.logger.Error("error creating request", "error", err, "path", path)
+		return err
+	}
+
+	req.Header.Add(WorkflowTokenHeader, w.token)
+	req.Header.Add(ActivityLogSequenceHeader, strconv.FormatUint(seq, 10))
+	if w.attemptID != "" {
+		req.Header.Add(ActivityLogAttemptHeader, w.attemptID)
+	}
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+	if compress {
+		req.Header.Add("Content-Encoding", "gzip")
+	}
+
+	group.Go(func() error {
+		defer pipew.Close()
+		defer body.Close()
+		defer writer.Close()
+
+		fw, err := writer.CreateFormFile(w.formField, w.formFileName)
+		if err != nil {
+			w.logger.Error("error creating form file", "error", err)
+			return err
+		}
+		_, err = io.Copy(fw, reader)
+		if err != nil {
+			w.logger.Error("error copying to writer", "error", err)
+			return err
+		}
+		return nil
+	})
+
+	group.Go(func() error {
+		resp, err := w.client.Do(req)
 		if err != nil {
-			w.logger.Error("error creating request", "error", err, "path", path)
+			w.logger.Error("error sending request", "error", err, "path", path)
 			return err
 		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusUnsupportedMediaType && compress {
+			return errUnsupportedMediaType
+		}
+		if resp.StatusCode != http.StatusOK {
+			w.logger.Error("error response", "status", resp.Status)
+			return errors.New("error: update activity log failed")
+		}
+		return nil
+	})
+
+	return group.Wait()
+}
+
+// spool persists a chunk that failed to upload, to be retried by a later flush.
+func (w *writer) spool(seq uint64, chunk []byte) error {
+	if err := os.MkdirAll(w.spoolDir, 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(w.spoolFile(seq), chunk, 0o600); err != nil {
+		return err
+	}
+	w.spooled = append(w.spooled, seq)
+	w.logger.Warn("spooled activity log chunk", "seq", seq, "bytes", len(chunk), "pending", len(w.spooled))
+	return nil
+}
 
-		req.Header.Add(WorkflowTokenHeader, w.token)
-		req.Header.Add("Content-Type", writer.FormDataContentType())
-
-		group.Go(func() error {
-			defer pipew.Close()
-			defer writer.Close()
-
-			fw, err := writer.CreateFormFile("content", "stdout")
-			if err != nil {
-				w.logger.Error("error creating form file", "error", err)
-				return err
-			}
-			_, err = io.Copy(fw, reader)
-			if err != nil {
-				w.logger.Error("error copying to writer", "error", err)
-				return err
-			}
-			return nil
-		})
-
-		group.Go(func() error {
-			resp, err := w.client.Do(req)
-			if err != nil {
-				w.logger.Error("error sending request", "error", err, "path", path)
-				return err
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusOK {
-				w.logger.Error("error response", "status", resp.Status)
-				return errors.New("error: update activity log failed")
-			}
-			return nil
-		})
-
-		err = group.Wait()
+// retrySpooled uploads the spooled chunks in order, stopping at the first failure.
+func (w *writer) retrySpooled(ctx context.Context) error {
+	for len(w.spooled) > 0 {
+		seq := w.spooled[0]
+		chunk, err := os.ReadFile(w.spoolFile(seq))
 		if err != nil {
 			return err
 		}
+		if err := w.upload(ctx, seq, chunk); err != nil {
+			return err
+		}
+		if err := os.Remove(w.spoolFile(seq)); err != nil {
+			return err
+		}
+		w.spooled = w.spooled[1:]
 	}
 	return nil
 }
 
+// acceptsEncoding reports whether the ActivityLogEncodingHeader value lists encoding.
+func acceptsEncoding(header, encoding string) bool {
+	for _, accepted := range strings.Split(header, ",") {
+		if strings.EqualFold(strings.TrimSpace(accepted), encoding) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *writer) spoolFile(seq uint64) string {
+	return filepath.Join(w.spoolDir, fmt.Sprintf("%s-%020d", w.formFileName, seq))
+}
+
+// isEmpty must be called with flushMu held.
 func (w *writer) isEmpty() bool {
 	w.Lock()
 	defer w.Unlock()
-	return len(w.buf) == 0
+	return len(w.buf) == 0 && w.dropped == 0 && len(w.spooled) == 0
 }
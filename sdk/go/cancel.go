@@ -0,0 +1,136 @@
+package sdk
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// ErrActivityCancelled is the cause of the context cancellation of an invocation
+// whose activity was cancelled by the engine. Use context.Cause(ctx) to detect it.
+var ErrActivityCancelled = errors.New("activity cancelled")
+
+// CancelRequest is the optional body of a request to /_/cancel/{activityID}.
+type CancelRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+type runningInvocation struct {
+	token  string
+	cancel context.CancelCauseFunc
+}
+
+// runningInvocations tracks the running invocations by activity ID so that they
+// can be cancelled through /_/cancel/{activityID}.
+type runningInvocations struct {
+	sync.Mutex
+
+	byActivity map[string]map[*runningInvocation]struct{}
+}
+
+func newRunningInvocations() *runningInvocations {
+	return &runningInvocations{byActivity: make(map[string]map[*runningInvocation]struct{})}
+}
+
+// add registers a running invocation and returns a func removing it again.
+func (r *runningInvocations) add(activityID, token string, cancel context.CancelCauseFunc) func() {
+	if activityID == "" {
+		return func() {}
+	}
+
+	inv := &runningInvocation{token: token, cancel: cancel}
+	r.Lock()
+	defer r.Unlock()
+	if r.byActivity[activityID] == nil {
+		r.byActivity[activityID] = make(map[*runningInvocation]struct{})
+	}
+	r.byActivity[activityID][inv] = struct{}{}
+
+	return func() {
+		r.Lock()
+		defer r.Unlock()
+		delete(r.byActivity[activityID], inv)
+		if len(r.byActivity[activityID]) == 0 {
+			delete(r.byActivity, activityID)
+		}
+	}
+}
+
+// cancel cancels the invocations of activityID started with token with cause. It
+// reports whether running invocations of activityID were started with token.
+func (r *runningInvocations) cancel(activityID, token string, cause error) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	invs := r.byActivity[activityID]
+	if len(invs) == 0 {
+		return false
+	}
+	for inv := range invs {
+		if subtle.ConstantTimeCompare([]byte(inv.token), []byte(token)) != 1 {
+			return false
+		}
+	}
+	for inv := range invs {
+		inv.cancel(cause)
+	}
+	return true
+}
+
+// cancelHandler cancels the running invocation of the activity in the path. The
+// request must carry the workflow token the invocation was started with; without
+// it, the request is rejected before revealing whether the activity is running.
+func (f *FunctionSDK) cancelHandler(w http.ResponseWriter, r *http.Request) {
+	activityID := r.PathValue("activityID")
+	logger := f.logger.With("activityID", activityID)
+
+	token := r.Header.Get(WorkflowTokenHeader)
+	if token == "" {
+		logger.Warn("rejecting cancel request without workflow token")
+		writeErrorResponse(w, logger, http.StatusUnauthorized, NewErrFailed("missing workflow token"))
+		return
+	}
+
+	var req CancelRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeErrorResponse(w, logger, http.StatusBadRequest, NewErrValidation("invalid cancel request", []FieldViolation{{Message: err.Error()}}))
+			return
+		}
+	}
+
+	cause := ErrActivityCancelled
+	if req.Reason != "" {
+		cause = fmt.Errorf("%w: %s", ErrActivityCancelled, req.Reason)
+	}
+
+	// a token that does not match answers like an activity that is not running, so
+	// that running activities cannot be probed with made-up tokens
+	if !f.running.cancel(activityID, token, cause) {
+		writeErrorResponse(w, logger, http.StatusNotFound, NewErrNotFound("no running invocation for activity and workflow token"))
+		return
+	}
+	logger.Info("cancelled running invocation", slog.String("reason", req.Reason))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// cancelledError turns the error of an invocation whose activity was cancelled into
+// an ErrFunction with ErrCodeCancelled, and the error of an invocation cancelled by
+// shutdown into a transient one so that the engine retries it on another replica.
+func cancelledError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, ErrActivityCancelled):
+		return NewErrCancelled(cause.Error())
+	case errors.Is(cause, ErrShuttingDown):
+		return NewErrTransient(cause.Error())
+	}
+	return err
+}
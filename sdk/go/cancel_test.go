@@ -0,0 +1,119 @@
+package sdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestCancelInvocation(t *testing.T) {
+	started := make(chan struct{})
+	causes := make(chan error, 1)
+	funcSDK, err := sdk.NewFunctionSDK(sdk.WithHandler(
+		func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			close(started)
+			<-ctx.Done()
+			causes <- context.Cause(ctx)
+			return nil, sdk.NewErrTransient("helm upgrade interrupted")
+		},
+	))
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	server := httptest.NewServer(funcSDK.Handler())
+	defer server.Close()
+
+	cancel := func(activityID, token, body string) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/_/cancel/"+activityID, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		req.Header.Set(sdk.WorkflowTokenHeader, token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := cancel("activity-1", "token", ""); status != http.StatusNotFound {
+		t.Errorf("Expected not found without running invocation, got %d", status)
+	}
+
+	// the /_/ paths never reach the handler, which would block until cancelled
+	for path, want := range map[string]int{
+		"/_/cancel/activity-1": http.StatusMethodNotAllowed,
+		"/_/unknown":           http.StatusNotFound,
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("Expected %d for GET %s, got %d", want, path, resp.StatusCode)
+		}
+	}
+
+	type response struct {
+		status int
+		result sdk.ErrFunction
+	}
+	responses := make(chan response, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+		req.Header.Set(sdk.ActivityIDHeader, "activity-1")
+		req.Header.Set(sdk.WorkflowTokenHeader, "token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("Error sending request: %v", err)
+			responses <- response{}
+			return
+		}
+		defer resp.Body.Close()
+		var result sdk.ErrFunction
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Errorf("Error decoding response: %v", err)
+		}
+		responses <- response{status: resp.StatusCode, result: result}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Invocation did not start")
+	}
+
+	if status := cancel("activity-1", "", ""); status != http.StatusUnauthorized {
+		t.Errorf("Expected unauthorized without token, got %d", status)
+	}
+	// a wrong token does not reveal that the activity is running
+	if status := cancel("activity-1", "wrong", ""); status != http.StatusNotFound {
+		t.Errorf("Expected not found with wrong token, got %d", status)
+	}
+	if status := cancel("activity-1", "token", `{"reason": "stopped by user"}`); status != http.StatusAccepted {
+		t.Errorf("Expected accepted, got %d", status)
+	}
+
+	cause := <-causes
+	if !errors.Is(cause, sdk.ErrActivityCancelled) || !strings.Contains(cause.Error(), "stopped by user") {
+		t.Errorf("Unexpected cancellation cause: %v", cause)
+	}
+
+	resp := <-responses
+	if resp.status != http.StatusInternalServerError || resp.result.ErrCode != sdk.ErrCodeCancelled {
+		t.Errorf("Expected cancelled error, got %d %+v", resp.status, resp.result)
+	}
+	if resp.result.Message != cause.Error() {
+		t.Errorf("Expected message %q, got %q", cause.Error(), resp.result.Message)
+	}
+}
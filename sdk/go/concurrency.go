@@ -0,0 +1,72 @@
+package sdk
+
+import (
+	"context"
+	"time"
+)
+
+// invocationLimiter bounds the number of concurrent invocations. A nil limiter
+// admits every invocation.
+type invocationLimiter struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+func newInvocationLimiter(max int, queueTimeout time.Duration) *invocationLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &invocationLimiter{
+		slots:        make(chan struct{}, max),
+		queueTimeout: queueTimeout,
+	}
+}
+
+// acquire reserves a slot, waiting up to the queue timeout when all slots are taken.
+// It reports whether a slot was reserved.
+func (l *invocationLimiter) acquire(ctx context.Context) bool {
+	if l == nil {
+		return true
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if l.queueTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *invocationLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// saturated reports whether every slot is taken.
+func (l *invocationLimiter) saturated() bool {
+	return l != nil && len(l.slots) >= cap(l.slots)
+}
+
+func (l *invocationLimiter) limit() int32 {
+	if l == nil {
+		return 0
+	}
+	return int32(cap(l.slots))
+}
@@ -0,0 +1,231 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configKeys maps the key paths of a config file to the option they set.
+var configKeys = map[string]func(val any) (SDKOption, error){
+	"server.port":               configInt(WithPort),
+	"server.unixSocket":         configString(WithUnixSocket),
+	"server.h2c":                configBool(WithH2C),
+	"server.readTimeout":        configDuration(WithReadTimeout),
+	"server.writeTimeout":       configDuration(WithWriteTimeout),
+	"server.shutdownTimeout":    configDuration(WithShutdownTimeout),
+	"server.execTimeout":        configDuration(WithExecTimeout),
+	"standalone.enabled":        configBool(WithStandalone),
+	"standalone.healthFile":     configString(WithHealthFile),
+	"standalone.healthInterval": configDuration(WithHealthInterval),
+	"log.level": func(val any) (SDKOption, error) {
+		var level slog.Level
+		s, err := configText(val)
+		if err == nil {
+			err = level.UnmarshalText([]byte(s))
+		}
+		return WithLogLevel(level), err
+	},
+	"log.format": func(val any) (SDKOption, error) {
+		s, err := configText(val)
+		if err == nil {
+			err = ActivityLogFormat(s).validate()
+		}
+		return WithActivityLogFormat(ActivityLogFormat(s)), err
+	},
+	"log.bufferPolicy": func(val any) (SDKOption, error) {
+		s, err := configText(val)
+		if err == nil {
+			err = BufferPolicy(s).validate()
+		}
+		return WithLogBufferPolicy(BufferPolicy(s)), err
+	},
+	"log.source":                 configBool(WithActivityLogSource),
+	"log.flushRate":              configDuration(WithLogFlushRate),
+	"log.maxBufferSize":          configInt(WithLogMaxBufferSize),
+	"log.flushThreshold":         configInt(WithLogFlushThreshold),
+	"log.compression":            configBool(WithLogCompression),
+	"log.spoolDir":               configString(WithLogSpoolDir),
+	"log.spoolTTL":               configDuration(WithLogSpoolTTL),
+	"log.writeTimeout":           configDuration(WithLogWriteTimeout),
+	"log.uploadRetries":          configInt(WithLogUploadRetryCount),
+	"tls.skipVerify":             configBool(WithServerSkipTLSVerify),
+	"concurrency.maxInvocations": configInt(WithMaxConcurrentInvocations),
+	"concurrency.queueTimeout":   configDuration(WithInvocationQueueTimeout),
+	"heartbeat.interval":         configDuration(WithHeartbeatInterval),
+	"idempotency.ttl":            configDuration(WithIdempotency),
+	"metrics.enabled":            configBool(WithMetrics),
+	// tracing.endpoint is read together with tracing.exporter
+	"tracing.exporter": nil,
+	"tracing.endpoint": nil,
+}
+
+// WithConfigFile loads options from the YAML or JSON file at path. Environment
+// variables, as read by NewFunctionSDKFromEnv, override the file and options
+// passed to NewFunctionSDK override both.
+func WithConfigFile(path string) SDKOption {
+	return func(o *SDKOptions) {
+		o.ConfigFile = path
+	}
+}
+
+// optionsFromFile returns the options set by the config file at path. Errors name
+// the file and the key path, such as config.yaml: log.level.
+func optionsFromFile(path string) ([]SDKOption, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// YAML is a superset of JSON, so this reads both
+	var doc map[string]any
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]any)
+	var errs []error
+	flattenConfig("", doc, values, func(key string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+	})
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var opts []SDKOption
+	for _, key := range keys {
+		parse, known := configKeys[key]
+		if !known {
+			errs = append(errs, fmt.Errorf("%s: %s: unknown key", path, key))
+			continue
+		}
+		if parse == nil {
+			continue
+		}
+		opt, err := parse(values[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+			continue
+		}
+		opts = append(opts, opt)
+	}
+
+	if exporter, ok := values["tracing.exporter"]; ok {
+		opt, key, err := configTracing(exporter, values["tracing.endpoint"])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+		} else {
+			opts = append(opts, opt)
+		}
+	} else if _, ok := values["tracing.endpoint"]; ok {
+		errs = append(errs, fmt.Errorf("%s: tracing.endpoint: requires tracing.exporter", path))
+	}
+
+	return opts, errors.Join(errs...)
+}
+
+// flattenConfig collects the leaves of doc keyed by their dotted path.
+func flattenConfig(prefix string, doc map[string]any, values map[string]any, onErr func(key string, err error)) {
+	for key, val := range doc {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		switch val := val.(type) {
+		case map[string]any:
+			flattenConfig(path, val, values, onErr)
+		case []any:
+			onErr(path, errors.New("lists are not supported"))
+		default:
+			values[path] = val
+		}
+	}
+}
+
+// configTracing returns the tracing option, or the key path of the invalid value.
+func configTracing(exporter, endpoint any) (SDKOption, string, error) {
+	name, err := configText(exporter)
+	if err != nil {
+		return nil, "tracing.exporter", err
+	}
+	switch TraceExporter(name) {
+	case TraceExporterNone, TraceExporterOTLP, TraceExporterFile:
+	default:
+		return nil, "tracing.exporter", fmt.Errorf("unknown trace exporter %q", name)
+	}
+
+	var url string
+	if endpoint != nil {
+		if url, err = configText(endpoint); err != nil {
+			return nil, "tracing.endpoint", err
+		}
+	}
+	return WithTracing(TraceExporter(name), url), "", nil
+}
+
+func configText(val any) (string, error) {
+	s, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a string, got %v", val)
+	}
+	return s, nil
+}
+
+func configString(opt func(string) SDKOption) func(any) (SDKOption, error) {
+	return func(val any) (SDKOption, error) {
+		s, err := configText(val)
+		return opt(s), err
+	}
+}
+
+func configBool(opt func(bool) SDKOption) func(any) (SDKOption, error) {
+	return func(val any) (SDKOption, error) {
+		b, ok := val.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected a boolean, got %v", val)
+		}
+		return opt(b), nil
+	}
+}
+
+func configInt(opt func(int) SDKOption) func(any) (SDKOption, error) {
+	return func(val any) (SDKOption, error) {
+		switch n := val.(type) {
+		case int:
+			return opt(n), nil
+		case float64:
+			if n == float64(int(n)) {
+				return opt(int(n)), nil
+			}
+		}
+		return nil, fmt.Errorf("expected an integer, got %v", val)
+	}
+}
+
+// configDuration accepts whole seconds or a duration string such as "30s".
+func configDuration(opt func(time.Duration) SDKOption) func(any) (SDKOption, error) {
+	return func(val any) (SDKOption, error) {
+		var s string
+		switch v := val.(type) {
+		case int:
+			s = strconv.Itoa(v)
+		case string:
+			s = strings.TrimSpace(v)
+		default:
+			return nil, fmt.Errorf("expected seconds or a duration such as 30s, got %v", val)
+		}
+		d, err := parseIntOrDuration(s)
+		return opt(d), err
+	}
+}
@@ -0,0 +1,163 @@
+package sdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestConfigFile(t *testing.T) {
+	handler := sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+		return sdk.Response{}, nil
+	})
+
+	writeConfig := func(name, content string) string {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Error writing config file: %v", err)
+		}
+		return path
+	}
+
+	// the function handler serves every other path, so check for the metrics themselves
+	metricsServed := func(opts ...sdk.SDKOption) bool {
+		funcSDK, err := sdk.NewFunctionSDK(append(opts, handler)...)
+		if err != nil {
+			t.Fatalf("Error creating function SDK: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		funcSDK.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_/metrics", nil))
+		return strings.Contains(rec.Body.String(), "function_invocations_in_flight")
+	}
+
+	yamlConfig := writeConfig("config.yaml", `
+server:
+  readTimeout: 3600
+  writeTimeout: 1h
+log:
+  level: debug
+  uploadRetries: 5
+concurrency:
+  maxInvocations: 4
+metrics:
+  enabled: false
+tracing:
+  exporter: ""
+`)
+	jsonConfig := writeConfig("config.json", `{"metrics": {"enabled": false}, "tls": {"skipVerify": true}}`)
+
+	for name, path := range map[string]string{"yaml": yamlConfig, "json": jsonConfig} {
+		t.Run(name, func(t *testing.T) {
+			if metricsServed(sdk.WithConfigFile(path)) {
+				t.Errorf("Expected metrics disabled by the config file")
+			}
+		})
+	}
+
+	t.Run("env overrides file", func(t *testing.T) {
+		t.Setenv("metrics_enabled", "true")
+		if !metricsServed(sdk.WithConfigFile(yamlConfig)) {
+			t.Errorf("Expected metrics enabled by the environment")
+		}
+		if metricsServed(sdk.WithConfigFile(yamlConfig), sdk.WithMetrics(false)) {
+			t.Errorf("Expected metrics disabled by the option")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		path := writeConfig("invalid.yaml", `
+server:
+  readTimeout: soon
+  port: "http"
+log:
+  level: loud
+  colour: true
+tracing:
+  endpoint: http://collector:4318
+`)
+		_, err := sdk.NewFunctionSDK(sdk.WithConfigFile(path), handler)
+		if err == nil {
+			t.Fatalf("Expected invalid config file to fail")
+		}
+		for _, key := range []string{"server.readTimeout", "server.port", "log.level", "log.colour", "tracing.endpoint"} {
+			if !strings.Contains(err.Error(), path+": "+key+": ") {
+				t.Errorf("Expected error to report %s, got %v", key, err)
+			}
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		if _, err := sdk.NewFunctionSDK(sdk.WithConfigFile(filepath.Join(t.TempDir(), "missing.yaml")), handler); err == nil {
+			t.Errorf("Expected missing config file to fail")
+		}
+	})
+}
+
+func TestConfigFileServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error finding a free port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := fmt.Sprintf("server:\n  port: %d\n  execTimeout: 100ms\n", port)
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+	// the port of the config file takes precedence over the of-watchdog default
+	t.Setenv("config_file", path)
+	t.Setenv("upstream_url", "")
+	t.Setenv("standalone", "")
+
+	funcSDK, err := sdk.NewFunctionSDKFromEnv(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+		<-ctx.Done()
+		return nil, context.Cause(ctx)
+	})
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- funcSDK.Run(ctx)
+	}()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/", port)
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		resp, err = http.Post(url, "application/json", strings.NewReader(`{}`))
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected the function to listen on port %d: %v", port, err)
+	}
+	var result map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	resp.Body.Close()
+	if result["message"] != sdk.ErrExecTimeoutExceeded.Error() {
+		t.Errorf("Expected the exec timeout of the config file, got %v", result)
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Error running function SDK: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrActivityDeadlineExceeded is the cause of the context cancellation of an
+// invocation that ran past the deadline sent by the engine.
+var ErrActivityDeadlineExceeded = errors.New("activity deadline exceeded")
+
+// RemainingTime returns the time left until the deadline of ctx. It returns false
+// when ctx has no deadline.
+func RemainingTime(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// activityDeadline returns the deadline the engine sent for the activity, either
+// as an absolute X-Activity-Deadline (RFC 3339 or unix seconds) or as a relative
+// X-Activity-Timeout (seconds or a Go duration). The absolute deadline wins when
+// both are set.
+func activityDeadline(header http.Header, now time.Time) (time.Time, bool, error) {
+	if val := header.Get(ActivityDeadlineHeader); val != "" {
+		if secs, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return time.Unix(secs, 0), true, nil
+		}
+		deadline, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s header %q", ActivityDeadlineHeader, val)
+		}
+		return deadline, true, nil
+	}
+
+	if val := header.Get(ActivityTimeoutHeader); val != "" {
+		if secs, err := strconv.Atoi(val); err == nil && secs > 0 {
+			return now.Add(time.Duration(secs) * time.Second), true, nil
+		}
+		timeout, err := time.ParseDuration(val)
+		if err != nil || timeout <= 0 {
+			return time.Time{}, false, fmt.Errorf("invalid %s header %q", ActivityTimeoutHeader, val)
+		}
+		return now.Add(timeout), true, nil
+	}
+
+	return time.Time{}, false, nil
+}
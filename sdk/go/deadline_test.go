@@ -0,0 +1,99 @@
+package sdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestRemainingTime(t *testing.T) {
+	if _, ok := sdk.RemainingTime(context.Background()); ok {
+		t.Errorf("Expected no remaining time without deadline")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	remaining, ok := sdk.RemainingTime(ctx)
+	if !ok || remaining <= 0 || remaining > time.Minute {
+		t.Errorf("Unexpected remaining time: %s, %v", remaining, ok)
+	}
+}
+
+func TestActivityDeadline(t *testing.T) {
+	funcSDK, err := sdk.NewFunctionSDK(sdk.WithHandler(
+		func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			if req["wait"] == true {
+				<-ctx.Done()
+				return nil, context.Cause(ctx)
+			}
+			remaining, ok := sdk.RemainingTime(ctx)
+			return sdk.Response{"remaining": remaining.Seconds(), "ok": ok}, nil
+		},
+	))
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	server := httptest.NewServer(funcSDK.Handler())
+	defer server.Close()
+
+	invoke := func(header, value, body string) map[string]any {
+		req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		defer resp.Body.Close()
+		var result map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("Error decoding response: %v", err)
+		}
+		return result
+	}
+
+	testcases := map[string]struct {
+		header  string
+		value   string
+		want    bool
+		maxSecs float64
+	}{
+		"no deadline":      {want: false},
+		"timeout seconds":  {header: sdk.ActivityTimeoutHeader, value: "30", want: true, maxSecs: 30},
+		"timeout duration": {header: sdk.ActivityTimeoutHeader, value: "1m", want: true, maxSecs: 60},
+		"deadline rfc3339": {header: sdk.ActivityDeadlineHeader, value: time.Now().Add(time.Minute).Format(time.RFC3339), want: true, maxSecs: 60},
+		"deadline unix":    {header: sdk.ActivityDeadlineHeader, value: strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10), want: true, maxSecs: 60},
+		"invalid timeout":  {header: sdk.ActivityTimeoutHeader, value: "soon", want: false},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			data := invoke(tc.header, tc.value, `{}`)["data"].(map[string]any)
+			if data["ok"] != tc.want {
+				t.Fatalf("Expected deadline %v, got %v", tc.want, data["ok"])
+			}
+			if remaining := data["remaining"].(float64); tc.want && (remaining <= 0 || remaining > tc.maxSecs) {
+				t.Errorf("Unexpected remaining time: %v", remaining)
+			}
+		})
+	}
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		result := invoke(sdk.ActivityTimeoutHeader, "100ms", `{"wait": true}`)
+		if result["message"] != sdk.ErrActivityDeadlineExceeded.Error() {
+			t.Errorf("Expected deadline exceeded cause, got %v", result)
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultStandalonePort is of-watchdog's default port, used when the function
+	// runs without it.
+	defaultStandalonePort = 8080
+	// defaultUpstreamPort is the port of-watchdog forwards to in the function
+	// templates, used when upstream_url is not set.
+	defaultUpstreamPort = 8082
+)
+
+// NewFunctionSDKFromEnv creates a FunctionSDK for handler configured from the
+// environment variables of-watchdog and the function template pass to the function,
+// such as read_timeout or log_level. Every invalid value is reported in the returned
+// error. opts are applied after the environment and take precedence over it.
+func NewFunctionSDKFromEnv(handler Handler, opts ...SDKOption) (*FunctionSDK, error) {
+	envOpts, err := optionsFromEnv(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	envOpts = append(envOpts, withWatchdogPorts(), WithHandler(handler))
+	return NewFunctionSDK(append(envOpts, opts...)...)
+}
+
+// withWatchdogPorts makes the function listen where of-watchdog expects it when no
+// port is set: of-watchdog's own port in standalone mode and the port of-watchdog
+// forwards to otherwise.
+func withWatchdogPorts() SDKOption {
+	return func(o *SDKOptions) {
+		o.watchdogPorts = true
+	}
+}
+
+// optionsFromEnv returns the options set by the environment variables found by lookup.
+func optionsFromEnv(lookup func(string) (string, bool)) ([]SDKOption, error) {
+	p := &envParser{lookup: lookup}
+
+	standalone := false
+	p.parse("standalone", func(val string) (SDKOption, error) {
+		enabled, err := strconv.ParseBool(val)
+		standalone = enabled
+		return WithStandalone(enabled), err
+	})
+	// behind of-watchdog, port is the watchdog's own port and the function
+	// listens where upstream_url points to
+	if standalone {
+		p.parse("port", func(val string) (SDKOption, error) {
+			port, err := parsePort(val)
+			return WithPort(port), err
+		})
+	} else {
+		p.parse("upstream_url", parseUpstreamURL)
+	}
+
+	p.parse("config_file", func(val string) (SDKOption, error) {
+		return WithConfigFile(val), nil
+	})
+	p.boolean("h2c", WithH2C)
+	p.duration("read_timeout", WithReadTimeout)
+	p.duration("write_timeout", WithWriteTimeout)
+	p.duration("exec_timeout", WithExecTimeout)
+	p.duration("healthcheck_interval", WithHealthInterval)
+	p.parse("health_file", func(val string) (SDKOption, error) {
+		return WithHealthFile(val), nil
+	})
+	p.duration("shutdown_timeout", WithShutdownTimeout)
+	p.parse("log_level", func(val string) (SDKOption, error) {
+		var level slog.Level
+		err := level.UnmarshalText([]byte(val))
+		return WithLogLevel(level), err
+	})
+	p.parse("activity_log_format", func(val string) (SDKOption, error) {
+		format := ActivityLogFormat(val)
+		return WithActivityLogFormat(format), format.validate()
+	})
+	p.boolean("activity_log_source", WithActivityLogSource)
+	p.duration("log_flush_rate", WithLogFlushRate)
+	p.integer("log_max_buffer_size", WithLogMaxBufferSize)
+	p.integer("log_flush_threshold", WithLogFlushThreshold)
+	p.boolean("log_compression", WithLogCompression)
+	p.parse("log_spool_dir", func(val string) (SDKOption, error) {
+		return WithLogSpoolDir(val), nil
+	})
+	p.duration("log_spool_ttl", WithLogSpoolTTL)
+	p.parse("log_buffer_policy", func(val string) (SDKOption, error) {
+		policy := BufferPolicy(val)
+		return WithLogBufferPolicy(policy), policy.validate()
+	})
+	p.duration("log_write_timeout", WithLogWriteTimeout)
+	p.integer("log_upload_retries", WithLogUploadRetryCount)
+	p.boolean("skip_tls_verify", WithServerSkipTLSVerify)
+	p.duration("heartbeat_interval", WithHeartbeatInterval)
+	p.integer("max_concurrent_invocations", WithMaxConcurrentInvocations)
+	p.duration("invocation_queue_timeout", WithInvocationQueueTimeout)
+	p.duration("idempotency_ttl", WithIdempotency)
+	p.boolean("metrics_enabled", WithMetrics)
+	p.parse("trace_exporter", func(val string) (SDKOption, error) {
+		exporter := TraceExporter(val)
+		switch exporter {
+		case TraceExporterNone, TraceExporterOTLP, TraceExporterFile:
+		default:
+			return nil, errors.New("unknown trace exporter")
+		}
+		endpoint, _ := lookup("trace_endpoint")
+		return WithTracing(exporter, endpoint), nil
+	})
+
+	return p.opts, errors.Join(p.errs...)
+}
+
+type envParser struct {
+	lookup func(string) (string, bool)
+	opts   []SDKOption
+	errs   []error
+}
+
+// parse converts the value of the environment variable name, if set, to an option.
+func (p *envParser) parse(name string, fn func(val string) (SDKOption, error)) {
+	val, ok := p.lookup(name)
+	if !ok || strings.TrimSpace(val) == "" {
+		return
+	}
+
+	opt, err := fn(strings.TrimSpace(val))
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid %s %q: %w", name, val, err))
+		return
+	}
+	p.opts = append(p.opts, opt)
+}
+
+func (p *envParser) duration(name string, opt func(time.Duration) SDKOption) {
+	p.parse(name, func(val string) (SDKOption, error) {
+		d, err := parseIntOrDuration(val)
+		return opt(d), err
+	})
+}
+
+func (p *envParser) integer(name string, opt func(int) SDKOption) {
+	p.parse(name, func(val string) (SDKOption, error) {
+		n, err := strconv.Atoi(val)
+		return opt(n), err
+	})
+}
+
+func (p *envParser) boolean(name string, opt func(bool) SDKOption) {
+	p.parse(name, func(val string) (SDKOption, error) {
+		b, err := strconv.ParseBool(val)
+		return opt(b), err
+	})
+}
+
+// parseIntOrDuration parses whole seconds, as of-watchdog does, or a Go duration.
+func parseIntOrDuration(val string) (time.Duration, error) {
+	if secs, err := strconv.Atoi(val); err == nil {
+		if secs < 0 {
+			return 0, errors.New("must not be negative")
+		}
+		return time.Duration(secs) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, errors.New("must be seconds or a duration such as 30s")
+	}
+	if d < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return d, nil
+}
+
+func parsePort(val string) (int, error) {
+	port, err := strconv.Atoi(val)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, errors.New("must be a port number")
+	}
+	return port, nil
+}
+
+// parseUpstreamURL listens where of-watchdog's upstream_url points to, either a
+// TCP URL such as http://127.0.0.1:8082 or a socket such as unix:///tmp/function.sock.
+func parseUpstreamURL(val string) (SDKOption, error) {
+	u, err := url.Parse(val)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "unix" {
+		if u.Path == "" {
+			return nil, errors.New("missing socket path")
+		}
+		return WithUnixSocket(u.Path), nil
+	}
+
+	port, err := parsePort(u.Port())
+	if err != nil {
+		return nil, err
+	}
+	return WithPort(port), nil
+}
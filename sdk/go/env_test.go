@@ -0,0 +1,160 @@
+package sdk_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestNewFunctionSDKFromEnv(t *testing.T) {
+	uploaded := make(chan struct{}, 1)
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case uploaded <- struct{}{}:
+		default:
+		}
+	}))
+	defer engine.Close()
+
+	dir, err := os.MkdirTemp("", "sdk")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "function.sock")
+
+	t.Setenv("upstream_url", "unix://"+socket)
+	t.Setenv("port", "8080")
+	t.Setenv("read_timeout", "30")
+	t.Setenv("write_timeout", "1m")
+	t.Setenv("log_level", "debug")
+	t.Setenv("log_flush_rate", "50ms")
+	t.Setenv("log_write_timeout", "5s")
+	t.Setenv("log_upload_retries", "1")
+	t.Setenv("skip_tls_verify", "false")
+
+	funcSDK, err := sdk.NewFunctionSDKFromEnv(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+		logger.Info("flushed before the invocation completes")
+		select {
+		case <-uploaded:
+			return sdk.Response{}, nil
+		case <-time.After(500 * time.Millisecond):
+			return nil, sdk.NewErrFailed("activity log was not flushed at log_flush_rate")
+		}
+	})
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- funcSDK.Run(ctx)
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+		Timeout: 5 * time.Second,
+	}
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		var req *http.Request
+		req, err = http.NewRequest(http.MethodPost, "http://function/", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		req.Header.Set(sdk.EngineAPIEndpointHeader, engine.URL)
+		req.Header.Set(sdk.ActivityFileUploadHeader, "/logs")
+		resp, err = client.Do(req)
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Error running function SDK: %v", err)
+	}
+}
+
+func TestNewFunctionSDKFromEnvDefaultPort(t *testing.T) {
+	// without upstream_url, e.g. with go run, the function listens where the
+	// template's of-watchdog forwards to
+	t.Setenv("upstream_url", "")
+	t.Setenv("standalone", "")
+
+	funcSDK, err := sdk.NewFunctionSDKFromEnv(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+		return sdk.Response{}, nil
+	})
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- funcSDK.Run(ctx)
+	}()
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		resp, err = http.Get("http://127.0.0.1:8082/_/ready")
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected the function to listen on port 8082: %v", err)
+	}
+	resp.Body.Close()
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Error running function SDK: %v", err)
+	}
+}
+
+func TestNewFunctionSDKFromEnvInvalid(t *testing.T) {
+	t.Setenv("standalone", "true")
+	t.Setenv("port", "http")
+	t.Setenv("read_timeout", "soon")
+	t.Setenv("log_level", "loud")
+	t.Setenv("log_upload_retries", "-")
+	t.Setenv("skip_tls_verify", "maybe")
+	t.Setenv("trace_exporter", "jaeger")
+
+	_, err := sdk.NewFunctionSDKFromEnv(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+		return sdk.Response{}, nil
+	})
+	if err == nil {
+		t.Fatalf("Expected invalid configuration to fail")
+	}
+	for _, name := range []string{"port", "read_timeout", "log_level", "log_upload_retries", "skip_tls_verify", "trace_exporter"} {
+		if !strings.Contains(err.Error(), "invalid "+name+" ") {
+			t.Errorf("Expected error to report %s, got %v", name, err)
+		}
+	}
+}
@@ -13,10 +13,30 @@ const (
 	ErrCodeTransient
 	ErrCodeNotFound
 	ErrCodeConflict
+	ErrCodeCancelled
 )
 
 type errorCode int
 
+func (c errorCode) String() string {
+	switch c {
+	case ErrCodeExecuteAgain:
+		return "execute_again"
+	case ErrCodeFailed:
+		return "failed"
+	case ErrCodeTransient:
+		return "transient"
+	case ErrCodeNotFound:
+		return "not_found"
+	case ErrCodeConflict:
+		return "conflict"
+	case ErrCodeCancelled:
+		return "cancelled"
+	default:
+		return "unspecified"
+	}
+}
+
 type ErrFunction struct {
 	ErrCode    errorCode      `json:"error_code"`
 	Message    string         `json:"message"`
@@ -198,3 +218,63 @@ func IsErrConflict(err error) bool {
 func NewErrConflict(msg string) error {
 	return &errConflict{Message: msg}
 }
+
+type errCancelled struct {
+	Message string
+}
+
+func (e *errCancelled) Error() string {
+	return e.Message
+}
+
+func (e *errCancelled) Is(err error) bool {
+	var ec *errCancelled
+	return errors.As(err, &ec)
+}
+
+func (e *errCancelled) Unwrap() error {
+	return &ErrFunction{Message: e.Message, ErrCode: ErrCodeCancelled}
+}
+
+func IsErrCancelled(err error) bool {
+	return errors.Is(err, &errCancelled{})
+}
+
+// NewErrCancelled reports that the activity was cancelled before it completed.
+func NewErrCancelled(msg string) error {
+	return &errCancelled{Message: msg}
+}
+
+// FieldViolation describes a single invalid field of a request or response.
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type errValidation struct {
+	Message    string
+	Violations []FieldViolation
+}
+
+func (e *errValidation) Error() string {
+	return e.Message
+}
+
+func (e *errValidation) Is(err error) bool {
+	var ev *errValidation
+	return errors.As(err, &ev)
+}
+
+func (e *errValidation) Unwrap() error {
+	return &ErrFunction{Message: e.Message, ErrCode: ErrCodeFailed, Data: map[string]any{"violations": e.Violations}}
+}
+
+func IsErrValidation(err error) bool {
+	return errors.Is(err, &errValidation{})
+}
+
+// NewErrValidation returns a permanent failure listing the offending fields in the
+// error data under the "violations" key.
+func NewErrValidation(msg string, violations []FieldViolation) error {
+	return &errValidation{Message: msg, Violations: violations}
+}
@@ -0,0 +1,146 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// HeartbeatRequest is sent to the engine on every heartbeat.
+type HeartbeatRequest struct {
+	Details any `json:"details,omitempty"`
+}
+
+// HeartbeatResponse is the engine's answer to a heartbeat.
+type HeartbeatResponse struct {
+	Cancelled bool `json:"cancelled"`
+}
+
+type heartbeatKey struct{}
+
+// heartbeat reports liveness of a running invocation to the engine and cancels
+// the invocation once the engine reports the activity cancelled.
+type heartbeat struct {
+	sync.Mutex
+
+	url      string
+	token    string
+	client   *http.Client
+	interval time.Duration
+	logger   *slog.Logger
+	cancel   context.CancelCauseFunc
+
+	details any
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startHeartbeat sends heartbeats every interval until the returned heartbeat is
+// stopped. The heartbeat is attached to the returned context for Heartbeat.
+func startHeartbeat(ctx context.Context, cancel context.CancelCauseFunc, logger *slog.Logger, client *http.Client, url, token string, interval time.Duration) (context.Context, *heartbeat) {
+	h := &heartbeat{
+		url:      url,
+		token:    token,
+		client:   client,
+		interval: interval,
+		logger:   logger,
+		cancel:   cancel,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go h.run(context.WithoutCancel(ctx))
+	return context.WithValue(ctx, heartbeatKey{}, h), h
+}
+
+// Heartbeat records details to be sent to the engine with the next heartbeat of the
+// invocation in ctx, replacing the details of earlier calls. details must encode to
+// JSON. It is a no-op when the engine did not request heartbeats.
+func Heartbeat(ctx context.Context, details any) {
+	h, ok := ctx.Value(heartbeatKey{}).(*heartbeat)
+	if !ok {
+		return
+	}
+
+	h.Lock()
+	h.details = details
+	h.Unlock()
+}
+
+func (h *heartbeat) Stop() {
+	close(h.stop)
+	<-h.done
+}
+
+func (h *heartbeat) run(ctx context.Context) {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			cancelled, err := h.send(ctx)
+			if err != nil {
+				h.logger.Warn("error sending heartbeat", "error", err)
+				continue
+			}
+			if cancelled {
+				h.logger.Info("activity cancelled by the engine")
+				h.cancel(ErrActivityCancelled)
+				return
+			}
+		}
+	}
+}
+
+func (h *heartbeat) send(ctx context.Context) (cancelled bool, err error) {
+	ctx, span := StartSpan(ctx, "activity.heartbeat")
+	defer func() { EndSpan(span, err) }()
+
+	h.Lock()
+	body, err := json.Marshal(HeartbeatRequest{Details: h.details})
+	h.Unlock()
+	if err != nil {
+		return false, fmt.Errorf("failed to encode heartbeat details: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.interval)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Add(WorkflowTokenHeader, h.token)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("heartbeat failed: %s", resp.Status)
+	}
+
+	var hbResp HeartbeatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&hbResp); err != nil {
+		return false, fmt.Errorf("invalid heartbeat response: %w", err)
+	}
+	span.SetAttributes(attribute.Bool("activity.cancelled", hbResp.Cancelled))
+
+	return hbResp.Cancelled, nil
+}
@@ -0,0 +1,94 @@
+package sdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		details []any
+		tokens  []string
+	)
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/heartbeat" {
+			return
+		}
+		var hbReq sdk.HeartbeatRequest
+		if err := json.NewDecoder(r.Body).Decode(&hbReq); err != nil {
+			t.Errorf("Error decoding heartbeat: %v", err)
+		}
+
+		mu.Lock()
+		details = append(details, hbReq.Details)
+		tokens = append(tokens, r.Header.Get(sdk.WorkflowTokenHeader))
+		cancelled := len(details) >= 3
+		mu.Unlock()
+
+		json.NewEncoder(w).Encode(sdk.HeartbeatResponse{Cancelled: cancelled})
+	}))
+	defer engine.Close()
+
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithHeartbeatInterval(50*time.Millisecond),
+		sdk.WithLogWriteTimeout(time.Second),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			sdk.Heartbeat(ctx, map[string]any{"step": "deploy"})
+			<-ctx.Done()
+			return nil, context.Cause(ctx)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	server := httptest.NewServer(funcSDK.Handler())
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set(sdk.EngineAPIEndpointHeader, engine.URL)
+	req.Header.Set(sdk.ActivityFileUploadHeader, "/logs")
+	req.Header.Set(sdk.ActivityHeartbeatHeader, "/heartbeat")
+	req.Header.Set(sdk.WorkflowTokenHeader, "token")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if result["message"] != sdk.ErrActivityCancelled.Error() {
+		t.Errorf("Expected activity cancelled cause, got %v", result)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(details) != 3 {
+		t.Fatalf("Expected 3 heartbeats, got %d", len(details))
+	}
+	for i := range details {
+		if step := details[i].(map[string]any)["step"]; step != "deploy" {
+			t.Errorf("Unexpected heartbeat details: %v", details[i])
+		}
+		if tokens[i] != "token" {
+			t.Errorf("Unexpected workflow token: %q", tokens[i])
+		}
+	}
+}
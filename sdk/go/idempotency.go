@@ -0,0 +1,163 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// InvocationResult is the HTTP response of a completed invocation.
+type InvocationResult struct {
+	StatusCode int             `json:"status_code"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// InvocationStore persists invocation results so that redelivered activities are
+// answered from the store even after the function restarted. Load returns nil
+// when no result is stored for the activity.
+type InvocationStore interface {
+	Load(ctx context.Context, req Request, activityID string) (*InvocationResult, error)
+	Store(ctx context.Context, req Request, activityID string, result *InvocationResult) error
+}
+
+type invocationCall struct {
+	done   chan struct{}
+	result *InvocationResult
+}
+
+type cachedInvocation struct {
+	result  *InvocationResult
+	expires time.Time
+}
+
+// idempotency deduplicates invocations by activity ID: a duplicate of a running
+// invocation joins it and a duplicate of a completed one gets its cached result.
+// A nil *idempotency runs every invocation.
+type idempotency struct {
+	sync.Mutex
+
+	ttl   time.Duration
+	store InvocationStore
+	calls map[string]*invocationCall
+	cache map[string]cachedInvocation
+}
+
+func newIdempotency(ttl time.Duration, store InvocationStore) *idempotency {
+	if ttl <= 0 && store == nil {
+		return nil
+	}
+	return &idempotency{
+		ttl:   ttl,
+		store: store,
+		calls: make(map[string]*invocationCall),
+		cache: make(map[string]cachedInvocation),
+	}
+}
+
+// do returns the result for activityID, running fn only if the activity is neither
+// running nor completed.
+func (i *idempotency) do(ctx context.Context, logger *slog.Logger, req Request, activityID string, fn func() *InvocationResult) *InvocationResult {
+	if i == nil || activityID == "" {
+		return fn()
+	}
+
+	i.Lock()
+	if cached, ok := i.cache[activityID]; ok && time.Now().Before(cached.expires) {
+		i.Unlock()
+		logger.Info("returning cached result of completed invocation")
+		return cached.result
+	}
+	if call, ok := i.calls[activityID]; ok {
+		i.Unlock()
+		logger.Info("joining running invocation")
+		select {
+		case <-call.done:
+			return call.result
+		case <-ctx.Done():
+			return errorResult(logger, http.StatusInternalServerError, NewErrTransient("cancelled while waiting for running invocation"))
+		}
+	}
+	call := &invocationCall{done: make(chan struct{})}
+	i.calls[activityID] = call
+	i.Unlock()
+
+	defer func() {
+		i.Lock()
+		delete(i.calls, activityID)
+		i.Unlock()
+		close(call.done)
+	}()
+
+	if i.store != nil {
+		result, err := i.store.Load(ctx, req, activityID)
+		if err != nil {
+			logger.Warn("failed to load invocation result", "error", err)
+		}
+		if result != nil {
+			logger.Info("returning stored result of completed invocation")
+			call.result = result
+			i.remember(activityID, result)
+			return result
+		}
+	}
+
+	call.result = fn()
+	if !isFinalResult(call.result) || interrupted(ctx) {
+		return call.result
+	}
+
+	i.remember(activityID, call.result)
+	if i.store != nil {
+		if err := i.store.Store(ctx, req, activityID, call.result); err != nil {
+			logger.Warn("failed to store invocation result", "error", err)
+		}
+	}
+	return call.result
+}
+
+func (i *idempotency) remember(activityID string, result *InvocationResult) {
+	if i.ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	i.Lock()
+	defer i.Unlock()
+	for id, cached := range i.cache {
+		if now.After(cached.expires) {
+			delete(i.cache, id)
+		}
+	}
+	i.cache[activityID] = cachedInvocation{result: result, expires: now.Add(i.ttl)}
+}
+
+// interrupted reports whether the invocation was cancelled by shutdown or by its
+// caller going away rather than by the activity itself. Its result must not be
+// replayed as the activity did not get to complete.
+func interrupted(ctx context.Context) bool {
+	cause := context.Cause(ctx)
+	return errors.Is(cause, ErrShuttingDown) || errors.Is(cause, context.Canceled)
+}
+
+// isFinalResult reports whether the result may be replayed. Errors the engine is
+// expected to retry, such as transient or execute-again errors, must run again.
+func isFinalResult(result *InvocationResult) bool {
+	if result.StatusCode == http.StatusOK {
+		return true
+	}
+
+	var errFunc ErrFunction
+	if err := json.Unmarshal(result.Body, &errFunc); err != nil {
+		return false
+	}
+	switch errFunc.ErrCode {
+	case ErrCodeFailed, ErrCodeNotFound, ErrCodeConflict, ErrCodeCancelled:
+		return true
+	default:
+		return false
+	}
+}
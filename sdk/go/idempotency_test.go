@@ -0,0 +1,208 @@
+package sdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+type memoryInvocationStore struct {
+	sync.Mutex
+	results map[string]*sdk.InvocationResult
+}
+
+func (s *memoryInvocationStore) Load(ctx context.Context, req sdk.Request, activityID string) (*sdk.InvocationResult, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.results[activityID], nil
+}
+
+func (s *memoryInvocationStore) Store(ctx context.Context, req sdk.Request, activityID string, result *sdk.InvocationResult) error {
+	s.Lock()
+	defer s.Unlock()
+	s.results[activityID] = result
+	return nil
+}
+
+func newCountingSDK(t *testing.T, calls *atomic.Int32, opts ...sdk.SDKOption) *httptest.Server {
+	t.Helper()
+	opts = append(opts, sdk.WithHandler(
+		func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			n := calls.Add(1)
+			if delay, ok := req["delay"].(string); ok {
+				d, _ := time.ParseDuration(delay)
+				select {
+				case <-time.After(d):
+				case <-ctx.Done():
+					return nil, context.Cause(ctx)
+				}
+			}
+			if req["error"] == "transient" {
+				return nil, sdk.NewErrTransient("transient")
+			}
+			return sdk.Response{"call": n}, nil
+		},
+	))
+	funcSDK, err := sdk.NewFunctionSDK(opts...)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+	server := httptest.NewServer(funcSDK.Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func invokeActivity(t *testing.T, server *httptest.Server, activityID, body string) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set(sdk.ActivityIDHeader, activityID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+	var result map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	return fmt.Sprint(result)
+}
+
+func TestIdempotentInvocation(t *testing.T) {
+	var calls atomic.Int32
+	server := newCountingSDK(t, &calls, sdk.WithIdempotency(time.Minute))
+
+	// concurrent duplicates join the running invocation
+	var wg sync.WaitGroup
+	results := make([]string, 3)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = invokeActivity(t, server, "activity1", `{"delay": "200ms"}`)
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("Expected one handler call for concurrent duplicates, got %d", calls.Load())
+	}
+	for _, result := range results {
+		if result != results[0] {
+			t.Errorf("Expected identical results, got %s and %s", result, results[0])
+		}
+	}
+
+	// a redelivery after completion replays the result
+	if got := invokeActivity(t, server, "activity1", `{}`); got != results[0] {
+		t.Errorf("Expected cached result %s, got %s", results[0], got)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected cached result to be replayed, got %d calls", calls.Load())
+	}
+
+	// other activities run
+	invokeActivity(t, server, "activity2", `{}`)
+	if calls.Load() != 2 {
+		t.Errorf("Expected a new activity to run, got %d calls", calls.Load())
+	}
+
+	// transient errors are retried
+	invokeActivity(t, server, "activity3", `{"error": "transient"}`)
+	invokeActivity(t, server, "activity3", `{"error": "transient"}`)
+	if calls.Load() != 4 {
+		t.Errorf("Expected transient errors not to be cached, got %d calls", calls.Load())
+	}
+}
+
+func TestIdempotentInvocationDisconnect(t *testing.T) {
+	store := &memoryInvocationStore{results: make(map[string]*sdk.InvocationResult)}
+	var calls atomic.Int32
+	server := newCountingSDK(t, &calls, sdk.WithIdempotency(time.Minute), sdk.WithInvocationStore(store))
+
+	// the first client goes away while the handler runs
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader(`{"delay": "300ms"}`))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set(sdk.ActivityIDHeader, "activity1")
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatalf("Expected the first request to be interrupted")
+	}
+
+	// the retry joins the invocation, which was not cancelled
+	if got := invokeActivity(t, server, "activity1", `{}`); got != "map[data:map[call:1]]" {
+		t.Errorf("Expected the retry to get the result of the first invocation, got %s", got)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected the handler to run once, got %d calls", calls.Load())
+	}
+
+	t.Run("without activity ID", func(t *testing.T) {
+		// nothing can join the invocation, so it is cancelled with its request
+		cancelled := make(chan error, 1)
+		funcSDK, err := sdk.NewFunctionSDK(
+			sdk.WithIdempotency(time.Minute),
+			sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+				select {
+				case <-ctx.Done():
+					cancelled <- ctx.Err()
+				case <-time.After(time.Second):
+					cancelled <- nil
+				}
+				return sdk.Response{}, nil
+			}),
+		)
+		if err != nil {
+			t.Fatalf("Error creating function SDK: %v", err)
+		}
+		server := httptest.NewServer(funcSDK.Handler())
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+
+		if err := <-cancelled; !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the invocation to be cancelled when the client disconnected, got %v", err)
+		}
+	})
+}
+
+func TestIdempotentInvocationStore(t *testing.T) {
+	store := &memoryInvocationStore{results: make(map[string]*sdk.InvocationResult)}
+
+	var calls atomic.Int32
+	first := newCountingSDK(t, &calls, sdk.WithInvocationStore(store))
+	want := invokeActivity(t, first, "activity1", `{}`)
+
+	// a restarted function answers from the store
+	restarted := newCountingSDK(t, &calls, sdk.WithInvocationStore(store))
+	if got := invokeActivity(t, restarted, "activity1", `{}`); got != want {
+		t.Errorf("Expected stored result %s, got %s", want, got)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected stored result to be replayed, got %d calls", calls.Load())
+	}
+}
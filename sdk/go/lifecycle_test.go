@@ -0,0 +1,164 @@
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestInitFailureStopsRun(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating listener: %v", err)
+	}
+
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithListener(listener),
+		sdk.WithInit(func(ctx context.Context) error {
+			return errors.New("boom")
+		}),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			return sdk.Response{}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	err = funcSDK.Run(context.Background())
+	if err == nil || err.Error() != "init: boom" {
+		t.Errorf("Expected init error, got %v", err)
+	}
+	if _, err := http.Get(fmt.Sprintf("http://%s/_/ready", listener.Addr().String())); err == nil {
+		t.Errorf("Expected the function not to serve after init failed")
+	}
+}
+
+func TestNotReadyDuringInit(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating listener: %v", err)
+	}
+
+	initDone := make(chan struct{})
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithListener(listener),
+		sdk.WithInit(func(ctx context.Context) error {
+			<-initDone
+			return nil
+		}),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			return sdk.Response{}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- funcSDK.Run(ctx)
+	}()
+
+	client := &http.Client{Timeout: time.Second}
+	ready := func() int {
+		var resp *http.Response
+		for i := 0; i < 50; i++ {
+			resp, err = client.Get(fmt.Sprintf("http://%s/_/ready", listener.Addr().String()))
+			if err == nil {
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("Error getting readiness: %v", err)
+		return 0
+	}
+
+	if status := ready(); status != http.StatusServiceUnavailable {
+		t.Errorf("Expected readiness to fail during init, got %d", status)
+	}
+	close(initDone)
+	for i := 0; i < 50 && ready() != http.StatusOK; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if status := ready(); status != http.StatusOK {
+		t.Errorf("Expected readiness to succeed after init, got %d", status)
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Error running function SDK: %v", err)
+	}
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating listener: %v", err)
+	}
+
+	var warmed, completed, shutdownAfterCompletion atomic.Bool
+	started := make(chan struct{})
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithListener(listener),
+		sdk.WithShutdownTimeout(5*time.Second),
+		sdk.WithInit(func(ctx context.Context) error {
+			warmed.Store(true)
+			return nil
+		}),
+		sdk.WithShutdown(func(ctx context.Context) error {
+			shutdownAfterCompletion.Store(completed.Load())
+			return nil
+		}),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			completed.Store(true)
+			return sdk.Response{"warmed": warmed.Load()}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- funcSDK.Run(ctx)
+	}()
+
+	invoked := make(chan error, 1)
+	go func() {
+		resp, err := http.Post(fmt.Sprintf("http://%s", listener.Addr().String()), "application/json", strings.NewReader(`{}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+		invoked <- err
+	}()
+
+	<-started
+	cancel()
+
+	if err := <-invoked; err != nil {
+		t.Errorf("Error invoking function: %v", err)
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("Error running function SDK: %v", err)
+	}
+	if !warmed.Load() {
+		t.Errorf("Expected init hook to run")
+	}
+	if !shutdownAfterCompletion.Load() {
+		t.Errorf("Expected shutdown hook to run after the in-flight invocation completed")
+	}
+}
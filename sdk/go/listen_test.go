@@ -0,0 +1,87 @@
+package sdk_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestUnixSocketH2C(t *testing.T) {
+	// socket paths are limited to ~100 bytes, so avoid the long t.TempDir paths
+	dir, err := os.MkdirTemp("", "sdk")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "function.sock")
+
+	// a socket left behind by an earlier run must not prevent listening
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Error creating stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithUnixSocket(socket),
+		sdk.WithH2C(true),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			return sdk.Response{}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- funcSDK.Run(ctx)
+	}()
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+		Protocols: new(http.Protocols),
+	}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		resp, err = client.Post("http://function/", "application/json", strings.NewReader(`{}`))
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Errorf("Expected HTTP/2, got %s", resp.Proto)
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Error running function SDK: %v", err)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("Expected socket to be removed on shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package sdk
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// ActivityLogFormat selects how log records uploaded to the engine are formatted.
+type ActivityLogFormat string
+
+const (
+	// ActivityLogFormatText writes logfmt lines such as level=INFO msg=deploying.
+	ActivityLogFormatText ActivityLogFormat = "text"
+	// ActivityLogFormatJSON writes one JSON object per line.
+	ActivityLogFormatJSON ActivityLogFormat = "json"
+)
+
+func (f ActivityLogFormat) validate() error {
+	switch f {
+	case ActivityLogFormatText, ActivityLogFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("unknown activity log format %q", f)
+	}
+}
+
+// newActivityLogHandler returns the handler formatting the records of an invocation
+// for the engine. Every record carries the activity and environment ID.
+func newActivityLogHandler(w io.Writer, format ActivityLogFormat, addSource bool, level slog.Level, activityID, environmentID string) slog.Handler {
+	opts := &slog.HandlerOptions{
+		AddSource: addSource,
+		Level:     level,
+	}
+
+	var handler slog.Handler
+	if format == ActivityLogFormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return handler.WithAttrs([]slog.Attr{
+		slog.String("activityID", activityID),
+		slog.String("environmentID", environmentID),
+	})
+}
@@ -0,0 +1,119 @@
+package sdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestActivityLogFormat(t *testing.T) {
+	invoke := func(t *testing.T, opts ...sdk.SDKOption) string {
+		var (
+			mu   sync.Mutex
+			logs strings.Builder
+		)
+		engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			file, _, err := r.FormFile("content")
+			if err != nil {
+				return
+			}
+			defer file.Close()
+			mu.Lock()
+			defer mu.Unlock()
+			_, _ = io.Copy(&logs, file)
+		}))
+		defer engine.Close()
+
+		opts = append(opts,
+			sdk.WithLogWriteTimeout(time.Second),
+			sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+				logger.Warn("disk almost full", "free", "1Gi")
+				return sdk.Response{}, nil
+			}),
+		)
+		funcSDK, err := sdk.NewFunctionSDK(opts...)
+		if err != nil {
+			t.Fatalf("Error creating function SDK: %v", err)
+		}
+		server := httptest.NewServer(funcSDK.Handler())
+		defer server.Close()
+
+		req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		req.Header.Set(sdk.EngineAPIEndpointHeader, engine.URL)
+		req.Header.Set(sdk.ActivityFileUploadHeader, "/logs")
+		req.Header.Set(sdk.ActivityIDHeader, "activity1")
+		req.Header.Set(sdk.EnvironmentIDHeader, "env1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		resp.Body.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+		return logs.String()
+	}
+
+	t.Run("text", func(t *testing.T) {
+		logs := invoke(t)
+		if !strings.Contains(logs, `level=WARN msg="disk almost full" activityID=activity1 environmentID=env1 free=1Gi`) {
+			t.Errorf("Unexpected activity log:\n%s", logs)
+		}
+		if strings.Contains(logs, "source=") {
+			t.Errorf("Expected no source annotations by default:\n%s", logs)
+		}
+	})
+
+	t.Run("text with source", func(t *testing.T) {
+		logs := invoke(t, sdk.WithActivityLogSource(true))
+		if !strings.Contains(logs, "source=") {
+			t.Errorf("Expected source annotations:\n%s", logs)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		logs := invoke(t, sdk.WithActivityLogFormat(sdk.ActivityLogFormatJSON))
+		var found bool
+		for _, line := range strings.Split(strings.TrimSpace(logs), "\n") {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("Expected JSON line, got %q: %v", line, err)
+			}
+			if record["activityID"] != "activity1" || record["environmentID"] != "env1" || record["level"] == nil {
+				t.Errorf("Expected activityID, environmentID and level in %v", record)
+			}
+			if record["msg"] == "disk almost full" {
+				found = true
+				if record["level"] != "WARN" || record["free"] != "1Gi" {
+					t.Errorf("Unexpected record: %v", record)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected handler record in activity log:\n%s", logs)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := sdk.NewFunctionSDK(
+			sdk.WithActivityLogFormat("xml"),
+			sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+				return sdk.Response{}, nil
+			}),
+		)
+		if err == nil {
+			t.Errorf("Expected unknown format to fail")
+		}
+	})
+}
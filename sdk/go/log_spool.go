@@ -0,0 +1,75 @@
+package sdk
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// startSpoolExpiry removes the activity log chunks left in the spool directory once
+// they are older than the spool TTL, checking when called and every TTL after, and
+// returns a func that stops it.
+func (f *FunctionSDK) startSpoolExpiry() func() {
+	if f.logSpoolDir == "" || f.logSpoolTTL <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(f.logSpoolTTL)
+		defer ticker.Stop()
+		for {
+			f.expireSpool(time.Now().Add(-f.logSpoolTTL))
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// expireSpool removes the attempt directories of the spool last written before
+// cutoff and the activity directories left empty.
+func (f *FunctionSDK) expireSpool(cutoff time.Time) {
+	activities, err := os.ReadDir(f.logSpoolDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			f.logger.Warn("[entrypoint] Error reading log spool directory", "error", err)
+		}
+		return
+	}
+
+	for _, activity := range activities {
+		if !activity.IsDir() {
+			continue
+		}
+		activityDir := filepath.Join(f.logSpoolDir, activity.Name())
+		attempts, err := os.ReadDir(activityDir)
+		if err != nil {
+			f.logger.Warn("[entrypoint] Error reading log spool directory", "error", err)
+			continue
+		}
+		for _, attempt := range attempts {
+			info, err := attempt.Info()
+			if err != nil || !info.ModTime().Before(cutoff) {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(activityDir, attempt.Name())); err != nil {
+				f.logger.Warn("[entrypoint] Error removing expired activity log chunks", "error", err)
+				continue
+			}
+			f.logger.Warn("[entrypoint] Removed expired activity log chunks", "activity", activity.Name(), "attempt", attempt.Name())
+		}
+		// fails while attempts are left
+		_ = os.Remove(activityDir)
+	}
+}
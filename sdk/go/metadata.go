@@ -0,0 +1,118 @@
+package sdk
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Metadata is the typed view of the request metadata the SDK derives from the
+// incoming engine headers.
+type Metadata struct {
+	ActivityID      string `json:"activityID"`
+	EnvironmentID   string `json:"environmentID"`
+	EnvironmentName string `json:"environmentName"`
+	OrganizationID  string `json:"organizationID"`
+	ProjectID       string `json:"projectID"`
+	StateStoreURL   string `json:"stateStoreUrl"`
+	StateStoreToken string `json:"stateStoreToken"`
+	EventSource     string `json:"eventSource"`
+	EventSourceName string `json:"eventSourceName"`
+	EventType       string `json:"eventType"`
+	// Extra holds the X- headers the SDK has no field for, keyed by their
+	// canonical name such as X-Engine-Region, so that new engine headers reach
+	// handlers without an SDK release.
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// metadataHeaders maps the metadata keys to the headers they are read from.
+var metadataHeaders = map[string]string{
+	"activityID":      ActivityIDHeader,
+	"environmentID":   EnvironmentIDHeader,
+	"environmentName": EnvironmentNameHeader,
+	"organizationID":  OrganizationIDHeader,
+	"projectID":       ProjectIDHeader,
+	"stateStoreUrl":   EaasStateEndpointHeader,
+	"stateStoreToken": EaasStateAPITokenHeader,
+	"eventSource":     EventSourceHeader,
+	"eventSourceName": EventSourceNameHeader,
+	"eventType":       EventTypeHeader,
+}
+
+// internalHeaders are consumed by the SDK itself and are not passed to handlers.
+var internalHeaders = map[string]bool{
+	WorkflowTokenHeader:       true,
+	EngineAPIEndpointHeader:   true,
+	ActivityFileUploadHeader:  true,
+	ActivityDeadlineHeader:    true,
+	ActivityTimeoutHeader:     true,
+	ActivityHeartbeatHeader:   true,
+	ActivityLogEncodingHeader: true,
+}
+
+// metadataFromHeader builds the metadata map injected into the request. Extra X-
+// headers are added under their canonical name; repeated headers are joined by
+// commas.
+func metadataFromHeader(header http.Header) map[string]string {
+	metadata := make(map[string]string, len(metadataHeaders))
+	known := make(map[string]bool, len(metadataHeaders))
+	for key, name := range metadataHeaders {
+		metadata[key] = header.Get(name)
+		known[http.CanonicalHeaderKey(name)] = true
+	}
+
+	for name, values := range header {
+		name = http.CanonicalHeaderKey(name)
+		if !strings.HasPrefix(name, "X-") || known[name] || internalHeaders[name] {
+			continue
+		}
+		metadata[name] = strings.Join(values, ",")
+	}
+	return metadata
+}
+
+// metadataMap returns the metadata map of the request, which is nil when the request
+// carries no metadata. Requests decoded from JSON carry it as map[string]any.
+func metadataMap(r Object) map[string]string {
+	switch m := r["metadata"].(type) {
+	case map[string]string:
+		return m
+	case map[string]any:
+		metadata := make(map[string]string, len(m))
+		for key, val := range m {
+			if s, ok := val.(string); ok {
+				metadata[key] = s
+			}
+		}
+		return metadata
+	default:
+		return nil
+	}
+}
+
+// Metadata returns the typed request metadata. Missing metadata, as in requests
+// built by unit tests, results in a zero Metadata.
+func (r Object) Metadata() Metadata {
+	m := metadataMap(r)
+	md := Metadata{
+		ActivityID:      m["activityID"],
+		EnvironmentID:   m["environmentID"],
+		EnvironmentName: m["environmentName"],
+		OrganizationID:  m["organizationID"],
+		ProjectID:       m["projectID"],
+		StateStoreURL:   m["stateStoreUrl"],
+		StateStoreToken: m["stateStoreToken"],
+		EventSource:     m["eventSource"],
+		EventSourceName: m["eventSourceName"],
+		EventType:       m["eventType"],
+	}
+	for key, val := range m {
+		if !strings.HasPrefix(key, "X-") {
+			continue
+		}
+		if md.Extra == nil {
+			md.Extra = make(map[string]string)
+		}
+		md.Extra[key] = val
+	}
+	return md
+}
@@ -0,0 +1,88 @@
+package sdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRequestMetadata(t *testing.T) {
+	var got sdk.Metadata
+	var metaString string
+	funcSDK, err := sdk.NewFunctionSDK(sdk.WithHandler(
+		func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			got = req.Metadata()
+			metaString = req.MetaString("X-Engine-Region")
+			return sdk.Response{}, nil
+		},
+	))
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	req.Header.Set(sdk.ActivityIDHeader, "activity1")
+	req.Header.Set(sdk.EnvironmentNameHeader, "prod")
+	req.Header.Set(sdk.EaasStateEndpointHeader, "http://state")
+	req.Header.Set(sdk.EventTypeHeader, "deploy")
+	req.Header.Set(sdk.WorkflowTokenHeader, "secret")
+	req.Header.Set("X-Engine-Region", "us-west")
+	req.Header.Add("X-Labels", "a")
+	req.Header.Add("X-Labels", "b")
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	funcSDK.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	want := sdk.Metadata{
+		ActivityID:      "activity1",
+		EnvironmentName: "prod",
+		StateStoreURL:   "http://state",
+		EventType:       "deploy",
+		Extra: map[string]string{
+			"X-Engine-Region": "us-west",
+			"X-Labels":        "a,b",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Metadata() mismatch (-want +got):\n%s", diff)
+	}
+	if metaString != "us-west" {
+		t.Errorf("Expected MetaString to return extra header, got %q", metaString)
+	}
+}
+
+func TestRequestMetadataForms(t *testing.T) {
+	t.Run("missing", func(t *testing.T) {
+		req := sdk.Request{}
+		if got := req.MetaString("activityID"); got != "" {
+			t.Errorf("Expected empty MetaString, got %q", got)
+		}
+		if diff := cmp.Diff(sdk.Metadata{}, req.Metadata()); diff != "" {
+			t.Errorf("Metadata() mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("decoded from JSON", func(t *testing.T) {
+		var req sdk.Request
+		if err := json.Unmarshal([]byte(`{"metadata": {"activityID": "activity1", "X-Custom": "value"}}`), &req); err != nil {
+			t.Fatalf("Error decoding request: %v", err)
+		}
+		want := sdk.Metadata{ActivityID: "activity1", Extra: map[string]string{"X-Custom": "value"}}
+		if diff := cmp.Diff(want, req.Metadata()); diff != "" {
+			t.Errorf("Metadata() mismatch (-want +got):\n%s", diff)
+		}
+		if got := req.MetaString("activityID"); got != "activity1" {
+			t.Errorf("Expected MetaString activity1, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package sdk
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "function"
+
+// metrics holds the collectors served at /_/metrics. A nil *metrics is valid
+// and records nothing, so the activity log writer can be used standalone.
+type metrics struct {
+	registry          *prometheus.Registry
+	invocations       *prometheus.CounterVec
+	duration          *prometheus.HistogramVec
+	inFlight          prometheus.Gauge
+	panics            prometheus.Counter
+	logUploadBytes    prometheus.Counter
+	logUploadFailures prometheus.Counter
+	logDroppedBytes   prometheus.Counter
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		invocations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "invocations_total",
+			Help:      "Number of function invocations by outcome.",
+		}, []string{"outcome"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Latency of the function handler by outcome.",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 1800, 3600},
+		}, []string{"outcome"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "invocations_in_flight",
+			Help:      "Number of function invocations currently running.",
+		}),
+		panics: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "panics_total",
+			Help:      "Number of panics recovered from the function handler.",
+		}),
+		logUploadBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "activity_log_upload_bytes_total",
+			Help:      "Number of activity log bytes uploaded to the engine.",
+		}),
+		logUploadFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "activity_log_upload_failures_total",
+			Help:      "Number of failed activity log uploads.",
+		}),
+		logDroppedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "activity_log_dropped_bytes_total",
+			Help:      "Number of activity log bytes dropped because the buffer was full.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.invocations,
+		m.duration,
+		m.inFlight,
+		m.panics,
+		m.logUploadBytes,
+		m.logUploadFailures,
+		m.logDroppedBytes,
+	)
+
+	return m
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// invocationStarted records the start of an invocation and returns a func to be
+// called with the invocation error once it completes.
+func (m *metrics) invocationStarted() func(err error) {
+	if m == nil {
+		return func(error) {}
+	}
+
+	start := time.Now()
+	m.inFlight.Inc()
+	return func(err error) {
+		outcome := invocationOutcome(err)
+		m.inFlight.Dec()
+		m.invocations.WithLabelValues(outcome).Inc()
+		m.duration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *metrics) panicRecovered() {
+	if m == nil {
+		return
+	}
+	m.panics.Inc()
+}
+
+func (m *metrics) logUploaded(n int) {
+	if m == nil {
+		return
+	}
+	m.logUploadBytes.Add(float64(n))
+}
+
+func (m *metrics) logUploadFailed() {
+	if m == nil {
+		return
+	}
+	m.logUploadFailures.Inc()
+}
+
+func (m *metrics) logDropped(n int) {
+	if m == nil {
+		return
+	}
+	m.logDroppedBytes.Add(float64(n))
+}
+
+// invocationOutcome maps an invocation error to the ErrFunction error code name.
+func invocationOutcome(err error) string {
+	if err == nil {
+		return "success"
+	}
+	errFunc, ok := AsErrFunction(err)
+	if !ok {
+		return ErrCodeFailed.String()
+	}
+	return errFunc.ErrCode.String()
+}
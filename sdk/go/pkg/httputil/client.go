@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 type retriableHTTPOptions struct {
@@ -200,7 +202,11 @@ func NewRetriableHTTPClient(opts ...RetriableHTTPOption) *retryablehttp.Client {
 
 	c := retryablehttp.Client{
 		HTTPClient: &http.Client{
-			Transport: transport,
+			// propagate the trace context of outgoing requests
+			Transport: otelhttp.NewTransport(transport, otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator(
+				propagation.TraceContext{},
+				propagation.Baggage{},
+			))),
 		},
 		Backoff:      options.backoff,
 		CheckRetry:   options.checkRetry,
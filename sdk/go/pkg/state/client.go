@@ -12,6 +12,8 @@ import (
 	sdk "github.com/RafaySystems/function-templates/sdk/go"
 	"github.com/RafaySystems/function-templates/sdk/go/pkg/httputil"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type StateScope struct {
@@ -108,7 +110,17 @@ func (b *boundState) injectHeaders(req *http.Request) {
 	req.Header.Set("Content-Type", "application/json")
 }
 
-func (b *boundState) Get(ctx context.Context, key string) (json.RawMessage, uint32, error) {
+func (b *boundState) startSpan(ctx context.Context, op, key string) (context.Context, trace.Span) {
+	return sdk.StartSpan(ctx, "stateclient."+op,
+		attribute.String("state.key", key),
+		attribute.String("state.organization_id", b.scope.OrganizationID),
+	)
+}
+
+func (b *boundState) Get(ctx context.Context, key string) (_ json.RawMessage, _ uint32, err error) {
+	ctx, span := b.startSpan(ctx, "Get", key)
+	defer func() { sdk.EndSpan(span, err) }()
+
 	var zero json.RawMessage
 	value, version, err := b.getRaw(ctx, key)
 	if err != nil {
@@ -118,7 +130,9 @@ func (b *boundState) Get(ctx context.Context, key string) (json.RawMessage, uint
 }
 
 // Developer passes key and value to set
-func (b *boundState) SetKV(ctx context.Context, key string, value json.RawMessage, version uint32) error {
+func (b *boundState) SetKV(ctx context.Context, key string, value json.RawMessage, version uint32) (err error) {
+	ctx, span := b.startSpan(ctx, "SetKV", key)
+	defer func() { sdk.EndSpan(span, err) }()
 
 	// Send update with version for OCC
 	body := map[string]any{
@@ -155,7 +169,10 @@ func (b *boundState) SetKV(ctx context.Context, key string, value json.RawMessag
 
 // Set abstracts create/update with OCC retry logic
 // Developer passes a transformation function that takes the old value and returns the new one.
-func (b *boundState) Set(ctx context.Context, key string, updateFn func(old json.RawMessage) (json.RawMessage, error)) error {
+func (b *boundState) Set(ctx context.Context, key string, updateFn func(old json.RawMessage) (json.RawMessage, error)) (err error) {
+	ctx, span := b.startSpan(ctx, "Set", key)
+	defer func() { sdk.EndSpan(span, err) }()
+
 	for retries := 0; retries < 5; retries++ {
 		// Step 1: Get latest raw value and version
 		raw, version, err := b.getRaw(ctx, key)
@@ -254,7 +271,9 @@ func (b *boundState) getRaw(ctx context.Context, key string) (json.RawMessage, u
 	return result.Value, result.Version, nil
 }
 
-func (b *boundState) Delete(ctx context.Context, key string) error {
+func (b *boundState) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := b.startSpan(ctx, "Delete", key)
+	defer func() { sdk.EndSpan(span, err) }()
 
 	body := map[string]any{
 		"scope": b.scope,
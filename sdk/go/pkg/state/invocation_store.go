@@ -0,0 +1,45 @@
+package stateclient
+
+import (
+	"context"
+	"encoding/json"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+const invocationKeyPrefix = "sdk/invocations/"
+
+type invocationStore struct{}
+
+// NewInvocationStore returns an sdk.InvocationStore that keeps invocation results in
+// the environment scope of the state store bound to each request. Use it with
+// sdk.WithInvocationStore so redelivered activities are not run twice across restarts.
+func NewInvocationStore() sdk.InvocationStore {
+	return &invocationStore{}
+}
+
+func (s *invocationStore) Load(ctx context.Context, req sdk.Request, activityID string) (*sdk.InvocationResult, error) {
+	raw, _, err := NewBoundState(req).WithEnvScope().Get(ctx, invocationKeyPrefix+activityID)
+	if sdk.IsErrNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result sdk.InvocationResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (s *invocationStore) Store(ctx context.Context, req sdk.Request, activityID string, result *sdk.InvocationResult) error {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return NewBoundState(req).WithEnvScope().Set(ctx, invocationKeyPrefix+activityID, func(json.RawMessage) (json.RawMessage, error) {
+		return raw, nil
+	})
+}
@@ -0,0 +1,61 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const (
+	progressFormField = "progress"
+	progressFileName  = "progress.jsonl"
+)
+
+// ProgressEvent is a machine-readable progress update of a running activity. The
+// events are uploaded to the engine as JSON lines next to the activity log.
+type ProgressEvent struct {
+	Step    string    `json:"step"`
+	Percent int       `json:"percent"`
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// ProgressReporter sends progress events of an invocation to the engine. A nil
+// *ProgressReporter discards the events.
+type ProgressReporter struct {
+	w io.Writer
+}
+
+type progressKey struct{}
+
+func withProgressReporter(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, progressKey{}, &ProgressReporter{w: w})
+}
+
+// Progress returns the progress reporter of the invocation in ctx, or nil outside
+// of an invocation.
+func Progress(ctx context.Context) *ProgressReporter {
+	p, _ := ctx.Value(progressKey{}).(*ProgressReporter)
+	return p
+}
+
+// Report sends a progress event for step. percent is clamped to 0-100.
+func (p *ProgressReporter) Report(step string, percent int, message string) {
+	if p == nil {
+		return
+	}
+
+	event := ProgressEvent{
+		Step:    step,
+		Percent: min(max(percent, 0), 100),
+		Message: message,
+		Time:    time.Now().UTC(),
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	// a single write keeps concurrent events on separate lines
+	_, _ = p.w.Write(append(line, '\n'))
+}
@@ -0,0 +1,101 @@
+package sdk_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestProgress(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []sdk.ProgressEvent
+		logs   strings.Builder
+	)
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("Error parsing upload: %v", err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for field, files := range r.MultipartForm.File {
+			file, err := files[0].Open()
+			if err != nil {
+				t.Errorf("Error opening upload: %v", err)
+				return
+			}
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				switch field {
+				case "progress":
+					var event sdk.ProgressEvent
+					if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+						t.Errorf("Error decoding progress event: %v", err)
+					}
+					events = append(events, event)
+				case "content":
+					logs.WriteString(scanner.Text() + "\n")
+				}
+			}
+			file.Close()
+		}
+	}))
+	defer engine.Close()
+
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithLogWriteTimeout(time.Second),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			progress := sdk.Progress(ctx)
+			progress.Report("plan", 10, "planning changes")
+			logger.Info("applying")
+			progress.Report("apply", 150, "")
+			return sdk.Response{}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	server := httptest.NewServer(funcSDK.Handler())
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set(sdk.EngineAPIEndpointHeader, engine.URL)
+	req.Header.Set(sdk.ActivityFileUploadHeader, "/logs")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 progress events, got %d", len(events))
+	}
+	if events[0].Step != "plan" || events[0].Percent != 10 || events[0].Message != "planning changes" || events[0].Time.IsZero() {
+		t.Errorf("Unexpected progress event: %+v", events[0])
+	}
+	if events[1].Step != "apply" || events[1].Percent != 100 {
+		t.Errorf("Unexpected progress event: %+v", events[1])
+	}
+	if !strings.Contains(logs.String(), "applying") || strings.Contains(logs.String(), "planning changes") {
+		t.Errorf("Unexpected activity log: %s", logs.String())
+	}
+
+	// outside of an invocation progress events are discarded
+	sdk.Progress(context.Background()).Report("plan", 10, "")
+}
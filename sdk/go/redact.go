@@ -0,0 +1,216 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redactedValue replaces secret values in log records.
+const redactedValue = "[REDACTED]"
+
+// secrets holds the secret values masked in the logs of an invocation.
+type secrets struct {
+	sync.Mutex
+
+	values   map[string]struct{}
+	replacer *strings.Replacer
+}
+
+func newSecrets(values ...string) *secrets {
+	s := &secrets{values: make(map[string]struct{})}
+	s.add(values...)
+	return s
+}
+
+func (s *secrets) add(values ...string) {
+	s.Lock()
+	defer s.Unlock()
+	for _, val := range values {
+		if val == "" {
+			continue
+		}
+		if _, ok := s.values[val]; !ok {
+			s.values[val] = struct{}{}
+			s.replacer = nil
+		}
+	}
+}
+
+// getReplacer returns a replacer masking every secret, or nil when there is none.
+func (s *secrets) getReplacer() *strings.Replacer {
+	s.Lock()
+	defer s.Unlock()
+	if s.replacer != nil || len(s.values) == 0 {
+		return s.replacer
+	}
+
+	// mask longer secrets first so that a secret containing another one is
+	// masked as a whole
+	values := make([]string, 0, len(s.values))
+	for val := range s.values {
+		values = append(values, val)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	oldnew := make([]string, 0, 2*len(values))
+	for _, val := range values {
+		oldnew = append(oldnew, val, redactedValue)
+	}
+	s.replacer = strings.NewReplacer(oldnew...)
+	return s.replacer
+}
+
+type secretsKey struct{}
+
+func withSecrets(ctx context.Context, s *secrets) context.Context {
+	return context.WithValue(ctx, secretsKey{}, s)
+}
+
+// RegisterSecret masks value in the logs of the invocation in ctx from now on, for
+// example a password fetched from a vault. It is a no-op outside of an invocation.
+func RegisterSecret(ctx context.Context, value string) {
+	if s, ok := ctx.Value(secretsKey{}).(*secrets); ok {
+		s.add(value)
+	}
+}
+
+// sensitiveValues returns the string values of the request fields at the given
+// dotted paths, such as credentials.password. Every string nested in a field is
+// returned, so a whole object can be marked sensitive.
+func sensitiveValues(req Request, fields []string) []string {
+	var values []string
+	for _, field := range fields {
+		var val any = map[string]any(req)
+		for _, key := range strings.Split(field, ".") {
+			obj, ok := val.(map[string]any)
+			if !ok {
+				val = nil
+				break
+			}
+			val = obj[key]
+		}
+		values = appendStrings(values, val)
+	}
+	return values
+}
+
+func appendStrings(values []string, val any) []string {
+	switch val := val.(type) {
+	case string:
+		values = append(values, val)
+	case map[string]any:
+		for _, v := range val {
+			values = appendStrings(values, v)
+		}
+	case []any:
+		for _, v := range val {
+			values = appendStrings(values, v)
+		}
+	}
+	return values
+}
+
+// redactingHandler masks secret values in the message and attributes of records
+// before passing them to the next handler.
+type redactingHandler struct {
+	next    slog.Handler
+	secrets *secrets
+	// bound holds the attributes and groups added with WithAttrs and WithGroup.
+	// They are passed to next on every record, as next formats them only once and
+	// secrets registered later would not be masked otherwise.
+	bound []boundAttrs
+}
+
+// boundAttrs is either a group opened with WithGroup or attributes added with
+// WithAttrs.
+type boundAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+func newRedactingHandler(next slog.Handler, s *secrets) slog.Handler {
+	return &redactingHandler{next: next, secrets: s}
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	replacer := h.secrets.getReplacer()
+	next := h.next
+	for _, b := range h.bound {
+		if b.group != "" {
+			next = next.WithGroup(b.group)
+			continue
+		}
+		attrs := b.attrs
+		if replacer != nil {
+			attrs = redactAttrs(replacer, attrs)
+		}
+		next = next.WithAttrs(attrs)
+	}
+	if replacer == nil {
+		return next.Handle(ctx, r)
+	}
+
+	redacted := slog.NewRecord(r.Time, r.Level, replacer.Replace(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(replacer, a))
+		return true
+	})
+	return next.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(boundAttrs{attrs: slices.Clone(attrs)})
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(boundAttrs{group: name})
+}
+
+func (h *redactingHandler) with(b boundAttrs) *redactingHandler {
+	return &redactingHandler{next: h.next, secrets: h.secrets, bound: append(slices.Clip(h.bound), b)}
+}
+
+func redactAttrs(replacer *strings.Replacer, attrs []slog.Attr) []slog.Attr {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(replacer, a)
+	}
+	return redacted
+}
+
+func redactAttr(replacer *strings.Replacer, a slog.Attr) slog.Attr {
+	return slog.Attr{Key: a.Key, Value: redactValue(replacer, a.Value)}
+}
+
+func redactValue(replacer *strings.Replacer, v slog.Value) slog.Value {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.StringValue(replacer.Replace(v.String()))
+	case slog.KindGroup:
+		return slog.GroupValue(redactAttrs(replacer, v.Group())...)
+	case slog.KindAny:
+		// values such as the request map or errors are masked in their printed
+		// form, which is only used when it contains a secret
+		printed := fmt.Sprintf("%+v", v.Any())
+		if masked := replacer.Replace(printed); masked != printed {
+			return slog.StringValue(masked)
+		}
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestSecretRedaction(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		logs strings.Builder
+	)
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("content")
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = io.Copy(&logs, file)
+	}))
+	defer engine.Close()
+
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithLogWriteTimeout(time.Second),
+		sdk.WithSensitiveFields("kubeconfig", "credentials.password"),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			// attributes bound before a secret is registered are masked as well
+			bound := logger.(*slog.Logger).With("token", "late-secret").WithGroup("db").With("password", "late-secret")
+			sdk.RegisterSecret(ctx, "late-secret")
+			bound.Info("connecting")
+			logger.Info("received request", "req", req)
+			sdk.RegisterSecret(ctx, "vault-secret")
+			logger.Info("fetched vault-secret from vault")
+			logger.Warn("login failed", "error", errors.New("bad password vault-secret"), slog.Group("auth", "password", "vault-secret"))
+			return sdk.Response{}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	server := httptest.NewServer(funcSDK.Handler())
+	defer server.Close()
+
+	body := `{"kubeconfig": {"users": [{"token": "kube-token"}]}, "credentials": {"user": "admin", "password": "hunter2"}}`
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set(sdk.EngineAPIEndpointHeader, engine.URL)
+	req.Header.Set(sdk.ActivityFileUploadHeader, "/logs")
+	req.Header.Set(sdk.WorkflowTokenHeader, "workflow-token")
+	req.Header.Set(sdk.EaasStateAPITokenHeader, "state-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	out := logs.String()
+	for _, secret := range []string{"workflow-token", "state-token", "kube-token", "hunter2", "vault-secret", "late-secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %q to be redacted from activity log:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{"admin", "fetched [REDACTED] from vault", "bad password [REDACTED]", "auth.password=[REDACTED]", "token=[REDACTED] db.password=[REDACTED]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected activity log to contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+)
+
+// Router dispatches requests to handlers registered per event, based on the
+// request's EventDetails. Its Handle method can be passed to WithHandler.
+type Router struct {
+	environmentDeploy  Handler
+	environmentDestroy Handler
+	workloadDeploy     Handler
+	workloadDestroy    Handler
+	forceDestroy       Handler
+	actions            map[string]Handler
+	schedules          map[string]Handler
+	fallback           Handler
+}
+
+var _ FunctionHandler = (*Router)(nil)
+
+// NewRouter creates an empty Router.
+func NewRouter() *Router {
+	return &Router{
+		actions:   make(map[string]Handler),
+		schedules: make(map[string]Handler),
+	}
+}
+
+// OnEnvironmentDeploy registers the handler for environment deploy events.
+func (r *Router) OnEnvironmentDeploy(h Handler) *Router {
+	r.environmentDeploy = h
+	return r
+}
+
+// OnEnvironmentDestroy registers the handler for environment destroy events. It also
+// receives environment force-destroy events unless OnForceDestroy is registered.
+func (r *Router) OnEnvironmentDestroy(h Handler) *Router {
+	r.environmentDestroy = h
+	return r
+}
+
+// OnWorkloadDeploy registers the handler for workload deploy events.
+func (r *Router) OnWorkloadDeploy(h Handler) *Router {
+	r.workloadDeploy = h
+	return r
+}
+
+// OnWorkloadDestroy registers the handler for workload destroy events. It also
+// receives workload force-destroy events unless OnForceDestroy is registered.
+func (r *Router) OnWorkloadDestroy(h Handler) *Router {
+	r.workloadDestroy = h
+	return r
+}
+
+// OnForceDestroy registers the handler for force-destroy events of any source. It
+// takes precedence over the destroy handlers.
+func (r *Router) OnForceDestroy(h Handler) *Router {
+	r.forceDestroy = h
+	return r
+}
+
+// OnAction registers the handler for the action with the given name.
+func (r *Router) OnAction(name string, h Handler) *Router {
+	r.actions[name] = h
+	return r
+}
+
+// OnSchedule registers the handler for the schedule with the given name.
+func (r *Router) OnSchedule(name string, h Handler) *Router {
+	r.schedules[name] = h
+	return r
+}
+
+// Fallback registers the handler for events no other handler matches.
+func (r *Router) Fallback(h Handler) *Router {
+	r.fallback = h
+	return r
+}
+
+// Handle dispatches req to the handler registered for its event. Events without a
+// matching handler and no fallback fail with ErrFailed.
+func (r *Router) Handle(ctx context.Context, logger Logger, req Request) (Response, error) {
+	event := NewEventDetails(req)
+	if h := r.route(event); h != nil {
+		return h(ctx, logger, req)
+	}
+	return nil, NewErrFailed(fmt.Sprintf("no handler registered for event: source %q, name %q, type %q",
+		event.Source, event.SourceName, event.Type))
+}
+
+func (r *Router) route(event *EventDetails) Handler {
+	var h Handler
+	switch {
+	case event.IsAction():
+		name, _ := event.GetActionName()
+		h = r.actions[name]
+	case event.IsSchedules():
+		name, _ := event.GetSchedulesName()
+		h = r.schedules[name]
+	case event.IsForceDestroy() && r.forceDestroy != nil:
+		h = r.forceDestroy
+	case event.IsWorkloadDeploy():
+		h = r.workloadDeploy
+	case event.IsWorkloadDestroy():
+		h = r.workloadDestroy
+	case event.IsEnvironmentDeploy():
+		h = r.environmentDeploy
+	case event.IsEnvironmentDestroy():
+		h = r.environmentDestroy
+	}
+
+	if h == nil {
+		return r.fallback
+	}
+	return h
+}
@@ -0,0 +1,85 @@
+package sdk_test
+
+import (
+	"context"
+	"testing"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func named(name string) sdk.Handler {
+	return func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+		return sdk.Response{"handler": name}, nil
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := sdk.NewRouter().
+		OnEnvironmentDeploy(named("environment-deploy")).
+		OnEnvironmentDestroy(named("environment-destroy")).
+		OnWorkloadDeploy(named("workload-deploy")).
+		OnWorkloadDestroy(named("workload-destroy")).
+		OnForceDestroy(named("force-destroy")).
+		OnAction("restart", named("action-restart")).
+		OnSchedule("nightly", named("schedule-nightly"))
+
+	tests := []struct {
+		name string
+		req  sdk.Request
+		want string
+	}{
+		{"environment deploy", requestWithEventMetadata("environment", "prod", "deploy"), "environment-deploy"},
+		{"environment destroy", requestWithEventMetadata("environment", "prod", "destroy"), "environment-destroy"},
+		{"workload deploy", requestWithEventMetadata("workload", "app", "deploy"), "workload-deploy"},
+		{"workload destroy", requestWithEventMetadata("workload", "app", "destroy"), "workload-destroy"},
+		{"environment force destroy", requestWithEventMetadata("environment", "prod", "force-destroy"), "force-destroy"},
+		{"workload force destroy", requestWithEventMetadata("workload", "app", "force-destroy"), "force-destroy"},
+		{"action", requestWithEventMetadata("action", "restart", "deploy"), "action-restart"},
+		{"schedule", requestWithEventMetadata("schedules", "nightly", "deploy"), "schedule-nightly"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := router.Handle(context.Background(), nil, tt.req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp["handler"] != tt.want {
+				t.Errorf("expected handler %s, got %v", tt.want, resp["handler"])
+			}
+		})
+	}
+}
+
+func TestRouterUnknownEvent(t *testing.T) {
+	router := sdk.NewRouter().OnAction("restart", named("action-restart"))
+
+	_, err := router.Handle(context.Background(), nil, requestWithEventMetadata("action", "stop", "deploy"))
+	if !sdk.IsErrFailed(err) {
+		t.Fatalf("expected ErrFailed, got %v", err)
+	}
+	want := `no handler registered for event: source "action", name "stop", type "deploy"`
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+
+	router.Fallback(named("fallback"))
+	resp, err := router.Handle(context.Background(), nil, requestWithEventMetadata("action", "stop", "deploy"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp["handler"] != "fallback" {
+		t.Errorf("expected fallback handler, got %v", resp["handler"])
+	}
+}
+
+func TestRouterForceDestroyWithoutHandler(t *testing.T) {
+	router := sdk.NewRouter().OnEnvironmentDestroy(named("environment-destroy"))
+
+	resp, err := router.Handle(context.Background(), nil, requestWithEventMetadata("environment", "prod", "force-destroy"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp["handler"] != "environment-destroy" {
+		t.Errorf("expected environment destroy handler, got %v", resp["handler"])
+	}
+}
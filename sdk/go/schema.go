@@ -0,0 +1,107 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+)
+
+// SchemaResponse is served at /_/schema so the engine can render input forms.
+type SchemaResponse struct {
+	Input  json.RawMessage `json:"input,omitempty"`
+	Output json.RawMessage `json:"output,omitempty"`
+}
+
+// schemaValidator validates a JSON document against a compiled JSON Schema.
+type schemaValidator struct {
+	raw    json.RawMessage
+	schema *jsonschema.Schema
+}
+
+func newSchemaValidator(name string, raw json.RawMessage) (*schemaValidator, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s schema: %w", name, err)
+	}
+
+	url := fmt.Sprintf("%s.schema.json", name)
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(url, doc); err != nil {
+		return nil, fmt.Errorf("invalid %s schema: %w", name, err)
+	}
+	schema, err := c.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s schema: %w", name, err)
+	}
+
+	return &schemaValidator{raw: raw, schema: schema}, nil
+}
+
+// validate checks obj against the schema and returns a validation error listing
+// every violation. The "metadata" key injected by the SDK is not validated.
+func (s *schemaValidator) validate(msg string, obj Object) error {
+	if s == nil {
+		return nil
+	}
+
+	body := make(Object, len(obj))
+	for key, val := range obj {
+		if key == "metadata" {
+			continue
+		}
+		body[key] = val
+	}
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return NewErrFailed(fmt.Sprintf("%s: %s", msg, err))
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return NewErrFailed(fmt.Sprintf("%s: %s", msg, err))
+	}
+
+	err = s.schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return NewErrFailed(fmt.Sprintf("%s: %s", msg, err))
+	}
+
+	var violations []FieldViolation
+	for _, unit := range verr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		if _, group := unit.Error.Kind.(*kind.Group); group {
+			continue
+		}
+		violations = append(violations, FieldViolation{
+			Field:   pointerToField(unit.InstanceLocation),
+			Message: unit.Error.String(),
+		})
+	}
+
+	return NewErrValidation(msg, violations)
+}
+
+// pointerToField converts a JSON pointer such as /chart/version to chart.version.
+func pointerToField(pointer string) string {
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		token = strings.ReplaceAll(token, "~1", "/")
+		tokens[i] = strings.ReplaceAll(token, "~0", "~")
+	}
+	return strings.Join(tokens, ".")
+}
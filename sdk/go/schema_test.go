@@ -0,0 +1,122 @@
+package sdk_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestSchemaValidation(t *testing.T) {
+	inputSchema := json.RawMessage(`{"type": "object", "properties": {"key": {"type": "string"}, "count": {"type": "integer"}}, "required": ["key"]}`)
+	outputSchema := json.RawMessage(`{"type": "object", "properties": {"output1": {"type": "string"}}}`)
+
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithInputSchema(inputSchema),
+		sdk.WithOutputSchema(outputSchema),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			if req["key"] == "invalid-input" {
+				return nil, fmt.Errorf("handler should not be invoked")
+			}
+			return sdk.Response{"output1": req["output1"]}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+	server := httptest.NewServer(funcSDK.Handler())
+	defer server.Close()
+
+	for name, tc := range map[string]struct {
+		body       string
+		statusCode int
+		response   sdk.Response
+		err        sdk.ErrFunction
+	}{
+		"valid": {
+			body:       `{"key": "valid", "output1": "value1"}`,
+			statusCode: http.StatusOK,
+			response:   sdk.Response{"output1": "value1"},
+		},
+		"invalid-input": {
+			body:       `{"key": "invalid-input", "count": "ten"}`,
+			statusCode: http.StatusInternalServerError,
+			err: sdk.ErrFunction{
+				Message: "invalid input",
+				ErrCode: sdk.ErrCodeFailed,
+				Data: map[string]any{"violations": []any{
+					map[string]any{"field": "count", "message": "got string, want integer"},
+				}},
+			},
+		},
+		"invalid-output": {
+			body:       `{"key": "invalid-output", "output1": 1}`,
+			statusCode: http.StatusInternalServerError,
+			err: sdk.ErrFunction{
+				Message: "invalid output",
+				ErrCode: sdk.ErrCodeFailed,
+				Data: map[string]any{"violations": []any{
+					map[string]any{"field": "output1", "message": "got number, want string"},
+				}},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := http.Post(server.URL, "application/json", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.statusCode {
+				t.Fatalf("Expected status %d, got %d", tc.statusCode, resp.StatusCode)
+			}
+
+			if tc.statusCode == http.StatusOK {
+				var result struct {
+					Data sdk.Response `json:"data"`
+				}
+				if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+					t.Fatalf("Error decoding response: %v", err)
+				}
+				if diff := cmp.Diff(tc.response, result.Data); diff != "" {
+					t.Errorf("Unexpected response: %s", diff)
+				}
+				return
+			}
+			var functionErr sdk.ErrFunction
+			if err := json.NewDecoder(resp.Body).Decode(&functionErr); err != nil {
+				t.Fatalf("Error decoding error response: %v", err)
+			}
+			if diff := cmp.Diff(tc.err, functionErr, cmpopts.IgnoreFields(sdk.ErrFunction{}, "StackTrace")); diff != "" {
+				t.Errorf("Unexpected error response: %s", diff)
+			}
+		})
+	}
+
+	t.Run("schema endpoint", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/_/schema")
+		if err != nil {
+			t.Fatalf("Error getting schema: %v", err)
+		}
+		defer resp.Body.Close()
+		var schemas sdk.SchemaResponse
+		if err := json.NewDecoder(resp.Body).Decode(&schemas); err != nil {
+			t.Fatalf("Error decoding schema response: %v", err)
+		}
+		for got, want := range map[string]json.RawMessage{string(schemas.Input): inputSchema, string(schemas.Output): outputSchema} {
+			var compacted bytes.Buffer
+			_ = json.Compact(&compacted, want)
+			if got != compacted.String() {
+				t.Errorf("Unexpected schema response: %s", got)
+			}
+		}
+	})
+}
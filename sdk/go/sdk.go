@@ -4,6 +4,7 @@ package sdk
 // Original license: MIT
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -12,23 +13,32 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"sync"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"time"
 
-	httputil "github.com/RafaySystems/envmgr-pkgs/http"
+	"github.com/RafaySystems/function-templates/sdk/go/pkg/httputil"
 	slogmulti "github.com/samber/slog-multi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var (
-	acceptingConnections int32
-)
+// ErrShuttingDown is the cause of the context cancellation of invocations that are
+// still running when the function shuts down. Use context.Cause(ctx) to detect it.
+var ErrShuttingDown = errors.New("function is shutting down")
+
+const drainPollInterval = 50 * time.Millisecond
 
 type SDKOptions struct {
 	Port                int
 	Listener            net.Listener
 	Handler             Handler
+	Middlewares         []Middleware
 	ReadTimeout         time.Duration
 	WriteTimeout        time.Duration
 	ShutdownTimeout     time.Duration
@@ -38,6 +48,39 @@ type SDKOptions struct {
 	LogFlushRate        time.Duration
 	LogWriteTimeout     time.Duration
 	SkipTLSVerify       bool
+	InputSchema         json.RawMessage
+	OutputSchema        json.RawMessage
+	MetricsEnabled      bool
+	TraceExporter       TraceExporter
+	TraceEndpoint       string
+	TracerProvider      trace.TracerProvider
+	MaxConcurrency      int
+	QueueTimeout        time.Duration
+	IdempotencyTTL      time.Duration
+	InvocationStore     InvocationStore
+	OnInit              func(ctx context.Context) error
+	OnShutdown          func(ctx context.Context) error
+	HeartbeatInterval   time.Duration
+	UnixSocket          string
+	H2C                 bool
+	Standalone          bool
+	HealthFile          string
+	ExecTimeout         time.Duration
+	ConfigFile          string
+	SensitiveFields     []string
+	ActivityLogFormat   ActivityLogFormat
+	ActivityLogSource   bool
+	LogMaxBufferSize    int
+	LogBufferPolicy     BufferPolicy
+	LogSpoolDir         string
+	LogSpoolTTL         time.Duration
+	LogFlushThreshold   int
+	LogCompression      bool
+
+	// portSet and watchdogPorts let NewFunctionSDKFromEnv default the port to the
+	// of-watchdog ports only when neither the config file nor the environment set it
+	portSet       bool
+	watchdogPorts bool
 }
 
 type SDKOption func(*SDKOptions)
@@ -45,6 +88,7 @@ type SDKOption func(*SDKOptions)
 func WithPort(port int) SDKOption {
 	return func(o *SDKOptions) {
 		o.Port = port
+		o.portSet = true
 	}
 }
 
@@ -54,12 +98,59 @@ func WithListener(listener net.Listener) SDKOption {
 	}
 }
 
+// WithUnixSocket listens on the Unix socket at path instead of the TCP port. A
+// stale socket left at path by an earlier run is removed.
+func WithUnixSocket(path string) SDKOption {
+	return func(o *SDKOptions) {
+		o.UnixSocket = path
+	}
+}
+
+// WithH2C serves HTTP/2 over cleartext connections in addition to HTTP/1.
+func WithH2C(enabled bool) SDKOption {
+	return func(o *SDKOptions) {
+		o.H2C = enabled
+	}
+}
+
+// WithStandalone runs the function without of-watchdog in front of it. The SDK then
+// keeps the health file that of-watchdog would keep, rewriting it every health
+// interval and removing it once the function drains.
+func WithStandalone(enabled bool) SDKOption {
+	return func(o *SDKOptions) {
+		o.Standalone = enabled
+	}
+}
+
+// WithHealthFile sets the health file kept in standalone mode, /tmp/.lock by default.
+func WithHealthFile(path string) SDKOption {
+	return func(o *SDKOptions) {
+		o.HealthFile = path
+	}
+}
+
+// WithExecTimeout cancels invocations running longer than timeout, as of-watchdog's
+// exec_timeout does. Zero means no timeout.
+func WithExecTimeout(timeout time.Duration) SDKOption {
+	return func(o *SDKOptions) {
+		o.ExecTimeout = timeout
+	}
+}
+
 func WithHandler(handler Handler) SDKOption {
 	return func(o *SDKOptions) {
 		o.Handler = handler
 	}
 }
 
+// WithMiddleware appends middlewares that wrap the handler. Middlewares run in the
+// order they are declared, the first one being the outermost.
+func WithMiddleware(middlewares ...Middleware) SDKOption {
+	return func(o *SDKOptions) {
+		o.Middlewares = append(o.Middlewares, middlewares...)
+	}
+}
+
 func WithReadTimeout(readTimeout time.Duration) SDKOption {
 	return func(o *SDKOptions) {
 		o.ReadTimeout = readTimeout
@@ -105,7 +196,7 @@ func WithShutdownTimeout(shutdownTimeout time.Duration) SDKOption {
 
 func WithLogFlushRate(logFlushRate time.Duration) SDKOption {
 	return func(o *SDKOptions) {
-		o.HealthInterval = logFlushRate
+		o.LogFlushRate = logFlushRate
 	}
 }
 
@@ -115,7 +206,184 @@ func WithServerSkipTLSVerify(skipTLSVerify bool) SDKOption {
 	}
 }
 
-func NewFunctionSDK(opts ...SDKOption) (*FunctionSDK, error) {
+// WithInputSchema sets the JSON Schema the request is validated against before
+// the handler is invoked.
+func WithInputSchema(schema json.RawMessage) SDKOption {
+	return func(o *SDKOptions) {
+		o.InputSchema = schema
+	}
+}
+
+// WithOutputSchema sets the JSON Schema the handler response is validated against
+// before it is returned.
+func WithOutputSchema(schema json.RawMessage) SDKOption {
+	return func(o *SDKOptions) {
+		o.OutputSchema = schema
+	}
+}
+
+// WithMetrics enables or disables the Prometheus metrics served at /_/metrics.
+func WithMetrics(enabled bool) SDKOption {
+	return func(o *SDKOptions) {
+		o.MetricsEnabled = enabled
+	}
+}
+
+// WithTracing exports invocation spans with the given exporter. endpoint is the
+// OTLP endpoint URL or the file path, depending on the exporter.
+func WithTracing(exporter TraceExporter, endpoint string) SDKOption {
+	return func(o *SDKOptions) {
+		o.TraceExporter = exporter
+		o.TraceEndpoint = endpoint
+	}
+}
+
+// WithTracerProvider uses tp for invocation spans instead of creating one.
+func WithTracerProvider(tp trace.TracerProvider) SDKOption {
+	return func(o *SDKOptions) {
+		o.TracerProvider = tp
+	}
+}
+
+// WithMaxConcurrentInvocations limits the number of invocations running at once.
+// Invocations beyond the limit are rejected with a transient error so the engine
+// retries them later. Zero or less means no limit.
+func WithMaxConcurrentInvocations(n int) SDKOption {
+	return func(o *SDKOptions) {
+		o.MaxConcurrency = n
+	}
+}
+
+// WithInvocationQueueTimeout makes invocations beyond the concurrency limit wait up
+// to timeout for a free slot before being rejected.
+func WithInvocationQueueTimeout(timeout time.Duration) SDKOption {
+	return func(o *SDKOptions) {
+		o.QueueTimeout = timeout
+	}
+}
+
+// WithIdempotency deduplicates invocations by activity ID. A redelivered activity
+// joins the running invocation or, once it completed, gets its result replayed for
+// up to ttl. Transient and execute-again errors are never replayed.
+func WithIdempotency(ttl time.Duration) SDKOption {
+	return func(o *SDKOptions) {
+		o.IdempotencyTTL = ttl
+	}
+}
+
+// WithInvocationStore persists completed invocation results in store so that they
+// are replayed even after a restart, see stateclient.NewInvocationStore.
+func WithInvocationStore(store InvocationStore) SDKOption {
+	return func(o *SDKOptions) {
+		o.InvocationStore = store
+	}
+}
+
+// WithActivityLogFormat sets the format of the logs uploaded to the engine, text
+// by default.
+func WithActivityLogFormat(format ActivityLogFormat) SDKOption {
+	return func(o *SDKOptions) {
+		o.ActivityLogFormat = format
+	}
+}
+
+// WithActivityLogSource annotates the logs uploaded to the engine with the source
+// file and line of the log call. It is off by default as it reveals build paths.
+func WithActivityLogSource(enabled bool) SDKOption {
+	return func(o *SDKOptions) {
+		o.ActivityLogSource = enabled
+	}
+}
+
+// WithLogMaxBufferSize bounds the activity log bytes buffered between uploads.
+// Zero, the default, leaves the buffer unbounded.
+func WithLogMaxBufferSize(size int) SDKOption {
+	return func(o *SDKOptions) {
+		o.LogMaxBufferSize = size
+	}
+}
+
+// WithLogBufferPolicy sets what happens once the activity log buffer is full,
+// BufferPolicyFlushEarly by default.
+func WithLogBufferPolicy(policy BufferPolicy) SDKOption {
+	return func(o *SDKOptions) {
+		o.LogBufferPolicy = policy
+	}
+}
+
+// WithLogSpoolDir persists activity log chunks that failed to upload under dir, one
+// directory per attempt at an activity, and retries them in order until the
+// invocation ends. Chunks still pending then are kept on disk, but not uploaded by
+// later runs, until they expire, see WithLogSpoolTTL. Invocations without an
+// activity ID are not spooled. Spooling is disabled by default.
+func WithLogSpoolDir(dir string) SDKOption {
+	return func(o *SDKOptions) {
+		o.LogSpoolDir = dir
+	}
+}
+
+// WithLogSpoolTTL sets how long chunks left in the spool directory are kept, 24h by
+// default. Expired chunks are removed when Run starts and every ttl after. Zero
+// keeps them.
+func WithLogSpoolTTL(ttl time.Duration) SDKOption {
+	return func(o *SDKOptions) {
+		o.LogSpoolTTL = ttl
+	}
+}
+
+// WithLogFlushThreshold uploads the activity log as soon as that many bytes are
+// buffered instead of waiting for the next flush tick, 256 KiB by default. Zero
+// only flushes on ticks.
+func WithLogFlushThreshold(size int) SDKOption {
+	return func(o *SDKOptions) {
+		o.LogFlushThreshold = size
+	}
+}
+
+// WithLogCompression gzips activity log uploads to engines that accept it. It is
+// enabled by default; older engines always get uncompressed uploads.
+func WithLogCompression(enabled bool) SDKOption {
+	return func(o *SDKOptions) {
+		o.LogCompression = enabled
+	}
+}
+
+// WithSensitiveFields masks the values of the given request fields in the logs of
+// an invocation. Fields are dotted paths such as credentials.password; every string
+// nested in a field is masked.
+func WithSensitiveFields(fields ...string) SDKOption {
+	return func(o *SDKOptions) {
+		o.SensitiveFields = append(o.SensitiveFields, fields...)
+	}
+}
+
+// WithInit registers a hook that runs once when Run starts, before the function
+// serves requests or reports ready. An error stops Run. Use it to load caches or
+// warm up clients shared by invocations.
+func WithInit(hook func(ctx context.Context) error) SDKOption {
+	return func(o *SDKOptions) {
+		o.OnInit = hook
+	}
+}
+
+// WithShutdown registers a hook that runs during Run's shutdown once the last
+// in-flight invocation completed. Use it to release resources set up by WithInit.
+func WithShutdown(hook func(ctx context.Context) error) SDKOption {
+	return func(o *SDKOptions) {
+		o.OnShutdown = hook
+	}
+}
+
+// WithHeartbeatInterval sets how often a running invocation reports a heartbeat to
+// the engine when the engine requested heartbeats. Zero disables heartbeats.
+func WithHeartbeatInterval(interval time.Duration) SDKOption {
+	return func(o *SDKOptions) {
+		o.HeartbeatInterval = interval
+	}
+}
+
+// applyOptions applies opts on top of the default options.
+func applyOptions(opts []SDKOption) *SDKOptions {
 	options := &SDKOptions{
 		Port:                5000,
 		Listener:            nil,
@@ -127,17 +395,71 @@ func NewFunctionSDK(opts ...SDKOption) (*FunctionSDK, error) {
 		LogUploadRetryCount: 3,
 		ShutdownTimeout:     10 * time.Second,
 		LogFlushRate:        1 * time.Second,
+		LogWriteTimeout:     10 * time.Second,
 		SkipTLSVerify:       false,
+		MetricsEnabled:      true,
+		HeartbeatInterval:   30 * time.Second,
+		HealthFile:          defaultHealthFile,
+		ActivityLogFormat:   ActivityLogFormatText,
+		LogBufferPolicy:     BufferPolicyFlushEarly,
+		LogSpoolTTL:         24 * time.Hour,
+		LogFlushThreshold:   256 << 10,
+		LogCompression:      true,
 	}
 
 	for _, o := range opts {
 		o(options)
 	}
+	if options.watchdogPorts && !options.portSet {
+		options.Port = defaultUpstreamPort
+		if options.Standalone {
+			options.Port = defaultStandalonePort
+		}
+	}
+	return options
+}
+
+func NewFunctionSDK(opts ...SDKOption) (*FunctionSDK, error) {
+	options := applyOptions(opts)
+	if options.ConfigFile != "" {
+		fileOpts, err := optionsFromFile(options.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		envOpts, err := optionsFromEnv(os.LookupEnv)
+		if err != nil {
+			return nil, err
+		}
+		options = applyOptions(append(append(fileOpts, envOpts...), opts...))
+	}
+
+	httpopts := []httputil.RetriableHTTPOption{httputil.WithMaxRetryCount(options.LogUploadRetryCount)}
+	if options.SkipTLSVerify {
+		httpopts = append(httpopts, httputil.WithTLSInsecureSkipVerify())
+	}
 
 	if options.Handler == nil {
 		return nil, fmt.Errorf("handler is required")
 	}
 
+	if err := options.ActivityLogFormat.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := options.LogBufferPolicy.validate(); err != nil {
+		return nil, err
+	}
+
+	inputSchema, err := newSchemaValidator("input", options.InputSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	outputSchema, err := newSchemaValidator("output", options.OutputSchema)
+	if err != nil {
+		return nil, err
+	}
+
 	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		AddSource: true,
 		Level:     slog.LevelInfo,
@@ -145,21 +467,66 @@ func NewFunctionSDK(opts ...SDKOption) (*FunctionSDK, error) {
 
 	logger := slog.New(handler)
 
-	return &FunctionSDK{
-		logger:          logger,
-		port:            options.Port,
-		listener:        options.Listener,
-		handler:         options.Handler,
-		readTimeout:     options.ReadTimeout,
-		writeTimeout:    options.WriteTimeout,
-		healthInterval:  options.HealthInterval,
-		logLevel:        options.LogLevel,
-		shutdownTimeout: options.ShutdownTimeout,
-		client:          httputil.NewRetriableHTTPClient(httputil.WithMaxRetryCount(options.LogUploadRetryCount)).StandardClient(),
-		logFlushRate:    options.LogFlushRate,
-		logWriteTimeout: options.LogWriteTimeout,
-		skipTLSVerify:   options.SkipTLSVerify,
-	}, nil
+	tracerProvider, tracerShutdown := options.TracerProvider, func(context.Context) error { return nil }
+	if tracerProvider == nil {
+		tracerProvider, tracerShutdown, err = newTracerProvider(options.TraceExporter, options.TraceEndpoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var m *metrics
+	if options.MetricsEnabled {
+		m = newMetrics()
+	}
+
+	invocationCtx, cancelInvocations := context.WithCancelCause(context.Background())
+
+	f := &FunctionSDK{
+		logger:            logger,
+		port:              options.Port,
+		listener:          options.Listener,
+		unixSocket:        options.UnixSocket,
+		h2c:               options.H2C,
+		standalone:        options.Standalone,
+		healthFile:        options.HealthFile,
+		execTimeout:       options.ExecTimeout,
+		sensitiveFields:   options.SensitiveFields,
+		activityLogFormat: options.ActivityLogFormat,
+		activityLogSource: options.ActivityLogSource,
+		logMaxBufferSize:  options.LogMaxBufferSize,
+		logBufferPolicy:   options.LogBufferPolicy,
+		logSpoolDir:       options.LogSpoolDir,
+		logSpoolTTL:       options.LogSpoolTTL,
+		logFlushThreshold: options.LogFlushThreshold,
+		logCompression:    options.LogCompression,
+		handler:           chainMiddlewares(options.Handler, options.Middlewares...),
+		readTimeout:       options.ReadTimeout,
+		writeTimeout:      options.WriteTimeout,
+		healthInterval:    options.HealthInterval,
+		logLevel:          options.LogLevel,
+		shutdownTimeout:   options.ShutdownTimeout,
+		client:            httputil.NewRetriableHTTPClient(httpopts...).StandardClient(),
+		logFlushRate:      options.LogFlushRate,
+		logWriteTimeout:   options.LogWriteTimeout,
+		skipTLSVerify:     options.SkipTLSVerify,
+		inputSchema:       inputSchema,
+		outputSchema:      outputSchema,
+		metrics:           m,
+		tracer:            tracerProvider.Tracer(TracerName),
+		tracerShutdown:    tracerShutdown,
+		limiter:           newInvocationLimiter(options.MaxConcurrency, options.QueueTimeout),
+		invocationCtx:     invocationCtx,
+		cancelInvocations: cancelInvocations,
+		idempotency:       newIdempotency(options.IdempotencyTTL, options.InvocationStore),
+		onInit:            options.OnInit,
+		onShutdown:        options.OnShutdown,
+		heartbeatInterval: options.HeartbeatInterval,
+		running:           newRunningInvocations(),
+	}
+	f.mux = f.newMux()
+
+	return f, nil
 
 }
 
@@ -167,6 +534,12 @@ type FunctionSDK struct {
 	logger          *slog.Logger
 	port            int
 	listener        net.Listener
+	unixSocket      string
+	h2c             bool
+	standalone      bool
+	healthFile      string
+	execTimeout     time.Duration
+	sensitiveFields []string
 	handler         Handler
 	readTimeout     time.Duration
 	writeTimeout    time.Duration
@@ -177,93 +550,370 @@ type FunctionSDK struct {
 	logFlushRate    time.Duration
 	logWriteTimeout time.Duration
 	skipTLSVerify   bool
+	inputSchema     *schemaValidator
+	outputSchema    *schemaValidator
+	metrics         *metrics
+	tracer          trace.Tracer
+	tracerShutdown  func(context.Context) error
+	limiter         *invocationLimiter
+	inFlight        atomic.Int32
+
+	invocationCtx     context.Context
+	cancelInvocations context.CancelCauseFunc
+	draining          atomic.Bool
+	mux               *http.ServeMux
+	idempotency       *idempotency
+	onInit            func(ctx context.Context) error
+	onShutdown        func(ctx context.Context) error
+	heartbeatInterval time.Duration
+	running           *runningInvocations
+	activityLogFormat ActivityLogFormat
+	activityLogSource bool
+	logMaxBufferSize  int
+	logBufferPolicy   BufferPolicy
+	logSpoolDir       string
+	logSpoolTTL       time.Duration
+	logFlushThreshold int
+	logCompression    bool
+	initializing      atomic.Bool
 }
 
-func (f *FunctionSDK) Run(ctx context.Context) error {
-	var listener net.Listener
-	var err error
-	if f.listener != nil {
-		listener = f.listener
-	} else {
-		listener, err = net.Listen("tcp", fmt.Sprintf(":%d", f.port))
-		if err != nil {
-			return err
+// Handler returns the http.Handler serving the function and its /_/ endpoints, so
+// the function can be embedded in another server or an httptest.Server.
+func (f *FunctionSDK) Handler() http.Handler {
+	return f.mux
+}
+
+// ready reports whether the function accepts invocations: it is neither running its
+// init hook, draining nor at its concurrency limit.
+func (f *FunctionSDK) ready() bool {
+	return !f.initializing.Load() && !f.draining.Load() && !f.limiter.saturated()
+}
+
+func (f *FunctionSDK) newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", f.getFunctionHandler())
+	mux.HandleFunc("/_/ready", func(w http.ResponseWriter, r *http.Request) {
+		var resp = ReadyResponse{
+			Ready:          f.ready(),
+			NumConnections: f.inFlight.Load(),
+			MaxConcurrency: f.limiter.limit(),
 		}
-	}
 
-	errChan := make(chan error, 1)
-	s := &http.Server{
-		Addr:           fmt.Sprintf(":%d", f.port),
-		ReadTimeout:    f.readTimeout,
-		WriteTimeout:   f.writeTimeout,
-		MaxHeaderBytes: 1 << 20, // Max header of 1MB
-	}
+		w.Header().Set("Content-Type", "application/json")
+		if resp.Ready {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
 
-	http.HandleFunc("/", f.getFunctionHandler())
-	http.HandleFunc("/_/ready", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/_/schema", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
 
-		var resp = ReadyResponse{
-			Ready:          true,
-			NumConnections: atomic.LoadInt32(&acceptingConnections),
+		var resp SchemaResponse
+		if f.inputSchema != nil {
+			resp.Input = f.inputSchema.raw
+		}
+		if f.outputSchema != nil {
+			resp.Output = f.outputSchema.raw
 		}
 
 		_ = json.NewEncoder(w).Encode(resp)
 	})
+	mux.HandleFunc("POST /_/cancel/{activityID}", f.cancelHandler)
+	// the /_/ paths are reserved for the SDK and never reach the function handler
+	mux.HandleFunc("/_/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/_/cancel/") && r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeErrorResponse(w, f.logger, http.StatusMethodNotAllowed, NewErrFailed("method not allowed"))
+			return
+		}
+		writeErrorResponse(w, f.logger, http.StatusNotFound, NewErrNotFound("unknown endpoint"))
+	})
+	if f.standalone {
+		mux.HandleFunc("/_/health", f.healthHandler)
+	}
+	if f.metrics != nil {
+		mux.Handle("/_/metrics", f.metrics.handler())
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(2)
+	return mux
+}
 
-	go func() {
-		defer wg.Done()
-		if err := s.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
-			f.logger.Error("[entrypoint] Error Serve", "error", err)
-			errChan <- err
-		}
-	}()
+func (f *FunctionSDK) Run(ctx context.Context) error {
+	listener, err := f.listen()
+	if err != nil {
+		return err
+	}
 
-	atomic.StoreInt32(&acceptingConnections, 1)
+	s := &http.Server{
+		Addr:           listener.Addr().String(),
+		ReadTimeout:    f.readTimeout,
+		WriteTimeout:   f.writeTimeout,
+		MaxHeaderBytes: 1 << 20, // Max header of 1MB
+		Handler:        f.mux,
+	}
+	if f.h2c {
+		s.Protocols = new(http.Protocols)
+		s.Protocols.SetHTTP1(true)
+		s.Protocols.SetUnencryptedHTTP2(true)
+	}
 
+	// serve while the init hook runs so that readiness probes get a 503 instead
+	// of hanging
+	f.initializing.Store(f.onInit != nil)
+	serveErr := make(chan error, 1)
 	go func() {
-		defer wg.Done()
-		<-ctx.Done()
-		atomic.StoreInt32(&acceptingConnections, 0)
-		shutdownctx, cancel := context.WithTimeout(context.Background(), f.shutdownTimeout)
-		defer cancel()
-		err := s.Shutdown(shutdownctx)
+		serveErr <- s.Serve(listener)
+	}()
+
+	if f.onInit != nil {
+		err := f.onInit(ctx)
+		f.initializing.Store(false)
 		if err != nil {
+			_ = s.Close()
+			<-serveErr
+			return errors.Join(fmt.Errorf("init: %w", err), f.shutdownTracer())
+		}
+	}
+	stopHealthReporting := f.startHealthReporting()
+	stopSpoolExpiry := f.startSpoolExpiry()
+	defer stopSpoolExpiry()
+
+	var errs []error
+	select {
+	case err := <-serveErr:
+		f.draining.Store(true)
+		stopHealthReporting()
+		f.logger.Error("[entrypoint] Error Serve", "error", err)
+		errs = append(errs, fmt.Errorf("serve: %w", err))
+		f.cancelInvocations(ErrShuttingDown)
+		f.waitInFlight(f.shutdownTimeout)
+		if err := f.runShutdownHook(); err != nil {
+			errs = append(errs, err)
+		}
+	case <-ctx.Done():
+		f.draining.Store(true)
+		stopHealthReporting()
+		if err := f.shutdown(s); err != nil {
 			f.logger.Error("[entrypoint] Error in Shutdown", "error", err)
-			errChan <- err
+			errs = append(errs, fmt.Errorf("shutdown: %w", err))
 		}
-	}()
+		if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+			f.logger.Error("[entrypoint] Error Serve", "error", err)
+			errs = append(errs, fmt.Errorf("serve: %w", err))
+		}
+		if err := f.runShutdownHook(); err != nil {
+			errs = append(errs, err)
+		}
+	}
 
-	wg.Wait()
+	errs = append(errs, f.shutdownTracer())
+	return errors.Join(errs...)
+}
 
+// listen returns the configured listener, or listens on the Unix socket or TCP port.
+func (f *FunctionSDK) listen() (net.Listener, error) {
+	if f.listener != nil {
+		return f.listener, nil
+	}
+	if f.unixSocket == "" {
+		return net.Listen("tcp", fmt.Sprintf(":%d", f.port))
+	}
+
+	if fi, err := os.Stat(f.unixSocket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(f.unixSocket); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	return net.Listen("unix", f.unixSocket)
+}
+
+func (f *FunctionSDK) shutdownTracer() error {
+	shutdownctx, cancel := context.WithTimeout(context.Background(), f.shutdownTimeout)
+	defer cancel()
+	if err := f.tracerShutdown(shutdownctx); err != nil {
+		f.logger.Error("[entrypoint] Error in tracer shutdown", "error", err)
+		return fmt.Errorf("tracer shutdown: %w", err)
+	}
 	return nil
 }
 
+// runShutdownHook runs the WithShutdown hook once no invocation is in flight.
+func (f *FunctionSDK) runShutdownHook() error {
+	if f.onShutdown == nil {
+		return nil
+	}
+
+	shutdownctx, cancel := context.WithTimeout(context.Background(), f.shutdownTimeout)
+	defer cancel()
+	if err := f.onShutdown(shutdownctx); err != nil {
+		f.logger.Error("[entrypoint] Error in shutdown hook", "error", err)
+		return fmt.Errorf("shutdown hook: %w", err)
+	}
+	return nil
+}
+
+// shutdown drains the function: readiness already reports 503 and new invocations
+// are rejected, so it waits up to the shutdown timeout for in-flight invocations.
+// Invocations still running after that have their context cancelled with
+// ErrShuttingDown and get another shutdown timeout to return and flush their logs.
+func (f *FunctionSDK) shutdown(s *http.Server) error {
+	var err error
+	if !f.waitInFlight(f.shutdownTimeout) {
+		f.logger.Warn("[entrypoint] cancelling in-flight invocations", "inFlight", f.inFlight.Load())
+		f.cancelInvocations(ErrShuttingDown)
+		err = fmt.Errorf("%d in-flight invocations did not complete within %s", f.inFlight.Load(), f.shutdownTimeout)
+		if !f.waitInFlight(f.shutdownTimeout) {
+			f.logger.Error("[entrypoint] in-flight invocations ignored cancellation", "inFlight", f.inFlight.Load())
+		}
+	}
+
+	shutdownctx, cancel := context.WithTimeout(context.Background(), f.shutdownTimeout)
+	defer cancel()
+	return errors.Join(err, s.Shutdown(shutdownctx))
+}
+
+// waitInFlight polls until no invocation is in flight or timeout elapses and
+// reports whether the function drained.
+func (f *FunctionSDK) waitInFlight(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for f.inFlight.Load() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(drainPollInterval)
+	}
+	return true
+}
+
 func (f *FunctionSDK) getFunctionHandler() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !f.limiter.acquire(r.Context()) {
+			f.logger.Warn("rejecting invocation, function is saturated", "activityID", r.Header.Get(ActivityIDHeader))
+			writeErrorResponse(w, f.logger, http.StatusInternalServerError, NewErrTransient("function is at its concurrency limit"))
+			return
+		}
+		defer f.limiter.release()
+
+		// count the invocation before checking whether the function is draining so
+		// that shutdown never misses it
+		f.inFlight.Add(1)
+		defer f.inFlight.Add(-1)
+		if f.draining.Load() {
+			writeErrorResponse(w, f.logger, http.StatusInternalServerError, NewErrTransient(ErrShuttingDown.Error()))
+			return
+		}
+		if f.initializing.Load() {
+			writeErrorResponse(w, f.logger, http.StatusInternalServerError, NewErrTransient("function is initializing"))
+			return
+		}
+
 		activityID := r.Header.Get(ActivityIDHeader)
+
+		// duplicates join the invocation started by the first request, so it must
+		// not be cancelled when the connection of that request drops
+		ctx := r.Context()
+		if f.idempotency != nil && activityID != "" {
+			ctx = context.WithoutCancel(ctx)
+		}
+
+		// cancel the invocation with the shutdown cause when the function shuts down
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+		stop := context.AfterFunc(f.invocationCtx, func() {
+			cancel(context.Cause(f.invocationCtx))
+		})
+		defer stop()
+		r = r.WithContext(ctx)
+
 		environmentID := r.Header.Get(EnvironmentIDHeader)
 		environmentName := r.Header.Get(EnvironmentNameHeader)
 		engineEndpoint := r.Header.Get(EngineAPIEndpointHeader)
 		fileUploadPath := r.Header.Get(ActivityFileUploadHeader)
+		defer f.running.add(activityID, r.Header.Get(WorkflowTokenHeader), cancel)()
+
+		ctx = Propagator.Extract(ctx, propagation.HeaderCarrier(r.Header))
+		ctx, span := f.tracer.Start(ctx, "function.invoke",
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("activity.id", activityID),
+				attribute.String("environment.id", environmentID),
+				attribute.String("environment.name", environmentName),
+				attribute.String("project.id", r.Header.Get(ProjectIDHeader)),
+				attribute.String("organization.id", r.Header.Get(OrganizationIDHeader)),
+			))
+		defer span.End()
+		r = r.WithContext(ctx)
 
 		currLogger := f.logger.With("activityID", activityID).
 			With("environmentID", environmentID).
 			With("environmentName", environmentName)
 
+		// the final flush must succeed even if the invocation was cancelled
+		logCtx := context.WithoutCancel(ctx)
+
+		deadline, ok, err := activityDeadline(r.Header, time.Now())
+		if err != nil {
+			currLogger.Warn("ignoring activity deadline", "error", err)
+		}
+		if ok {
+			var cancelDeadline, cancelLogs context.CancelFunc
+			ctx, cancelDeadline = context.WithDeadlineCause(ctx, deadline, ErrActivityDeadlineExceeded)
+			defer cancelDeadline()
+			r = r.WithContext(ctx)
+			span.SetAttributes(attribute.String("activity.deadline", deadline.Format(time.RFC3339)))
+
+			// leave the log writer time for its final flush once the deadline passed
+			logCtx, cancelLogs = context.WithDeadline(logCtx, deadline.Add(f.logWriteTimeout))
+			defer cancelLogs()
+		}
+		if f.execTimeout > 0 {
+			var cancelExec context.CancelFunc
+			ctx, cancelExec = context.WithTimeoutCause(ctx, f.execTimeout, ErrExecTimeoutExceeded)
+			defer cancelExec()
+			r = r.WithContext(ctx)
+		}
+
+		if heartbeatPath := r.Header.Get(ActivityHeartbeatHeader); heartbeatPath != "" && f.heartbeatInterval > 0 {
+			var hb *heartbeat
+			ctx, hb = startHeartbeat(ctx, cancel, currLogger, f.client, engineEndpoint+heartbeatPath, r.Header.Get(WorkflowTokenHeader), f.heartbeatInterval)
+			defer hb.Stop()
+			r = r.WithContext(ctx)
+		}
+
+		// the log and progress writers number their uploads in one sequence per
+		// attempt, so a redelivered activity starts a new sequence
+		attemptID := NewAttemptID()
+		writerOpts := []WriterOption{WithLogReqTimeout(f.logWriteTimeout), WithWriteFlushTickRate(f.logFlushRate), WithFlushThreshold(f.logFlushThreshold), WithSequence(new(atomic.Uint64)), WithAttemptID(attemptID), withHTTPClient(f.client)}
+		if f.logCompression && acceptsEncoding(r.Header.Get(ActivityLogEncodingHeader), "gzip") {
+			writerOpts = append(writerOpts, WithGzip(true))
+		}
+		if f.logSpoolDir != "" && activityID != "" {
+			// removed after the writers removed the attempt's directory, unless
+			// other attempts left chunks in it
+			activityDir := filepath.Join(f.logSpoolDir, url.PathEscape(activityID))
+			defer func() { _ = os.Remove(activityDir) }()
+			writerOpts = append(writerOpts, WithSpoolDir(filepath.Join(activityDir, attemptID)))
+		}
 		url := engineEndpoint + fileUploadPath
-		logWriter := NewActivityLogWriter(r.Context(), currLogger, url, r.Header.Get(WorkflowTokenHeader), WithLogReqTimeout(f.logWriteTimeout), WithWriteFlushTickRate(f.logFlushRate), WithSkipTLSVerify(f.skipTLSVerify))
+		logWriter := NewActivityLogWriter(logCtx, currLogger, url, r.Header.Get(WorkflowTokenHeader), append(writerOpts, WithMaxBufferSize(f.logMaxBufferSize, f.logBufferPolicy), withWriterMetrics(f.metrics))...)
 		defer logWriter.Close()
 
-		logger := slog.New(slogmulti.Fanout(slog.NewTextHandler(logWriter, &slog.HandlerOptions{
-			AddSource: true,
-			Level:     f.logLevel,
-		}), currLogger.Handler()))
+		progressWriter := NewActivityLogWriter(logCtx, currLogger, url, r.Header.Get(WorkflowTokenHeader), append(writerOpts, withFormFile(progressFormField, progressFileName))...)
+		defer progressWriter.Close()
+		r = r.WithContext(withProgressReporter(r.Context(), progressWriter))
+
+		// mask the tokens sent by the engine and secrets registered later on
+		invocationSecrets := newSecrets(r.Header.Get(WorkflowTokenHeader), r.Header.Get(EaasStateAPITokenHeader))
+		r = r.WithContext(withSecrets(r.Context(), invocationSecrets))
+
+		activityLogHandler := newActivityLogHandler(logWriter, f.activityLogFormat, f.activityLogSource, f.logLevel, activityID, environmentID)
+		logger := slog.New(newRedactingHandler(slogmulti.Fanout(activityLogHandler, currLogger.Handler()), invocationSecrets))
 		logger.Info("invoking function")
 
 		currLogger.Info("invoking function")
@@ -296,8 +946,7 @@ func (f *FunctionSDK) makeRequestHandler(logger *slog.Logger) http.HandlerFunc {
 		if len(input) > 0 {
 			err := json.Unmarshal(input, &req)
 			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				_, _ = w.Write([]byte("Invalid input"))
+				writeErrorResponse(w, logger, http.StatusBadRequest, NewErrValidation("invalid input", []FieldViolation{{Message: err.Error()}}))
 				return
 			}
 		}
@@ -305,43 +954,90 @@ func (f *FunctionSDK) makeRequestHandler(logger *slog.Logger) http.HandlerFunc {
 		if req == nil {
 			req = make(Request)
 		}
-		req["metadata"] = map[string]string{
-			"activityID":      r.Header.Get(ActivityIDHeader),
-			"environmentID":   r.Header.Get(EnvironmentIDHeader),
-			"environmentName": r.Header.Get(EnvironmentNameHeader),
-			"organizationID":  r.Header.Get(OrganizationIDHeader),
-			"projectID":       r.Header.Get(ProjectIDHeader),
-			"stateStoreUrl":   r.Header.Get(EaasStateEndpointHeader),
-			"stateStoreToken": r.Header.Get(EaasStateAPITokenHeader),
-			"eventSource":     r.Header.Get(EventSourceHeader),
-			"eventSourceName": r.Header.Get(EventSourceNameHeader),
-			"eventType":       r.Header.Get(EventTypeHeader),
+		req["metadata"] = metadataFromHeader(r.Header)
+		for _, val := range sensitiveValues(req, f.sensitiveFields) {
+			RegisterSecret(r.Context(), val)
 		}
 
-		result, err := f.invokeHandler(r.Context(), logger, req)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			errFunc, ok := AsErrFunction(err)
-			if !ok {
-				errFunc = &ErrFunction{Message: err.Error(), ErrCode: ErrCodeFailed}
-			}
-			if err = json.NewEncoder(w).Encode(errFunc); err != nil {
-				logger.Error("Error in encoding error response", "error", err)
+		result := f.idempotency.do(r.Context(), logger, req, r.Header.Get(ActivityIDHeader), func() *InvocationResult {
+			done := f.metrics.invocationStarted()
+
+			result, err := f.invoke(r.Context(), logger, req)
+			done(err)
+			span := trace.SpanFromContext(r.Context())
+			span.SetAttributes(attribute.String("function.outcome", invocationOutcome(err)))
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return errorResult(logger, http.StatusInternalServerError, err)
 			}
-			return
-		}
 
-		w.WriteHeader(http.StatusOK)
-		if err = json.NewEncoder(w).Encode(map[string]any{"data": result}); err != nil {
-			logger.Error("Error in encoding response", "error", err)
+			return encodeResult(logger, http.StatusOK, map[string]any{"data": result})
+		})
+
+		writeResult(w, result)
+	}
+}
+
+// invoke validates the request, runs the handler and validates its response.
+func (f *FunctionSDK) invoke(ctx context.Context, logger *slog.Logger, req Request) (Response, error) {
+	if err := f.inputSchema.validate("invalid input", req); err != nil {
+		return nil, err
+	}
+
+	result, err := f.invokeHandler(ctx, logger, req)
+	if err != nil {
+		return nil, cancelledError(ctx, err)
+	}
+
+	if err := f.outputSchema.validate("invalid output", result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func writeErrorResponse(w http.ResponseWriter, logger *slog.Logger, statusCode int, err error) {
+	writeResult(w, errorResult(logger, statusCode, err))
+}
+
+func writeResult(w http.ResponseWriter, result *InvocationResult) {
+	w.WriteHeader(result.StatusCode)
+	_, _ = w.Write(result.Body)
+}
+
+func errorResult(logger *slog.Logger, statusCode int, err error) *InvocationResult {
+	errFunc, ok := AsErrFunction(err)
+	if !ok {
+		errFunc = &ErrFunction{Message: err.Error(), ErrCode: ErrCodeFailed}
+	}
+	return encodeResult(logger, statusCode, errFunc)
+}
+
+func encodeResult(logger *slog.Logger, statusCode int, v any) *InvocationResult {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		logger.Error("Error in encoding response", "error", err)
+		return &InvocationResult{StatusCode: http.StatusInternalServerError}
+	}
+	return &InvocationResult{StatusCode: statusCode, Body: buf.Bytes()}
+}
+
+// chainMiddlewares wraps handler so that middlewares[0] is invoked first.
+func chainMiddlewares(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] == nil {
+			continue
 		}
+		handler = middlewares[i](handler)
 	}
+	return handler
 }
 
 func (f *FunctionSDK) invokeHandler(ctx context.Context, logger *slog.Logger, req Request) (r Response, err error) {
 	defer func() {
 		if rec := recover(); rec != nil {
 			logger.Error("Panic in function", "panic", rec)
+			f.metrics.panicRecovered()
 			err = newErrFailedWithStackTrace(fmt.Sprintf("Panic in function: %v", rec))
 		}
 	}()
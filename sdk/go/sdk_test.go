@@ -10,12 +10,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	sdk "github.com/RafaySystems/function-templates/sdk/go"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestFunctionSDK(t *testing.T) {
@@ -94,6 +97,33 @@ func TestFunctionSDK(t *testing.T) {
 			},
 			statusCode: http.StatusInternalServerError,
 		},
+		"middleware-order": {
+			handler: func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+				return sdk.Response{"order": req["order"]}, nil
+			},
+			response:   sdk.Response{"order": []any{"first", "second"}},
+			statusCode: http.StatusOK,
+		},
+		"middleware-short-circuit": {
+			handler: func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+				return nil, fmt.Errorf("handler should not be invoked")
+			},
+			err: sdk.ErrFunction{
+				Message: "blocked by middleware",
+				ErrCode: sdk.ErrCodeFailed,
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	recordOrder := func(name string) sdk.Middleware {
+		return func(next sdk.Handler) sdk.Handler {
+			return func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+				order, _ := req["order"].([]string)
+				req["order"] = append(order, name)
+				return next(ctx, logger, req)
+			}
+		}
 	}
 
 	logs := make(map[string][]byte)
@@ -127,10 +157,26 @@ func TestFunctionSDK(t *testing.T) {
 		t.Errorf("Error creating listener: %v", err)
 		return
 	}
+	spans := tracetest.NewSpanRecorder()
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
 	funcSDK, err := sdk.NewFunctionSDK(
 		sdk.WithListener(listener),
+		sdk.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
 		sdk.WithReadTimeout(20*time.Second),
 		sdk.WithWriteTimeout(20*time.Second),
+		sdk.WithMiddleware(
+			func(next sdk.Handler) sdk.Handler {
+				return func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+					if req["key"] == "middleware-short-circuit" {
+						return nil, sdk.NewErrFailed("blocked by middleware")
+					}
+					return next(ctx, logger, req)
+				}
+			},
+			recordOrder("first"),
+		),
+		sdk.WithMiddleware(recordOrder("second")),
 		sdk.WithHandler(
 			func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
 				if key, ok := req["key"].(string); ok {
@@ -168,6 +214,7 @@ func TestFunctionSDK(t *testing.T) {
 		req.Header.Set(sdk.EnvironmentNameHeader, "environment1Name")
 		req.Header.Set(sdk.EngineAPIEndpointHeader, server.URL)
 		req.Header.Set(sdk.ActivityFileUploadHeader, fmt.Sprintf("/activity/%s/log", key))
+		req.Header.Set("traceparent", fmt.Sprintf("00-%s-00f067aa0ba902b7-01", traceID))
 
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
@@ -175,6 +222,10 @@ func TestFunctionSDK(t *testing.T) {
 			return
 		}
 
+		if resp.StatusCode != tc.statusCode {
+			t.Errorf("%s: expected status %d, got %d", key, tc.statusCode, resp.StatusCode)
+		}
+
 		if resp.StatusCode == http.StatusInternalServerError {
 			if resp.Body != nil {
 				defer resp.Body.Close()
@@ -213,6 +264,47 @@ func TestFunctionSDK(t *testing.T) {
 		}
 	}
 
+	resp, err := http.Get(fmt.Sprintf("http://%s/_/metrics", listener.Addr().String()))
+	if err != nil {
+		t.Fatalf("Error getting metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	metrics, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		`function_invocations_total{outcome="success"} 2`,
+		`function_invocations_total{outcome="failed"} 4`,
+		`function_invocations_total{outcome="transient"} 1`,
+		`function_invocations_total{outcome="execute_again"} 1`,
+		`function_invocations_in_flight 0`,
+		`function_panics_total 1`,
+		`function_handler_duration_seconds_count{outcome="success"} 2`,
+	} {
+		if !strings.Contains(string(metrics), want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+
+	invocations := 0
+	for _, span := range spans.Ended() {
+		if span.SpanContext().TraceID().String() != traceID {
+			t.Errorf("Span %s not part of the propagated trace", span.Name())
+		}
+		if span.Name() != "function.invoke" {
+			continue
+		}
+		invocations++
+		attrs := make(map[string]string)
+		for _, attr := range span.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+		if attrs["activity.id"] != "activity1" || attrs["environment.id"] != "environment1" {
+			t.Errorf("Unexpected span attributes: %v", attrs)
+		}
+	}
+	if invocations != len(testcases) {
+		t.Errorf("Expected %d invocation spans, got %d", len(testcases), invocations)
+	}
+
 	// check logs if empty
 	for _, log := range logs {
 		if len(log) == 0 {
@@ -221,3 +313,248 @@ func TestFunctionSDK(t *testing.T) {
 	}
 
 }
+
+func TestFunctionSDKHandler(t *testing.T) {
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			funcSDK, err := sdk.NewFunctionSDK(sdk.WithHandler(
+				func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+					return sdk.Response{"instance": name}, nil
+				},
+			))
+			if err != nil {
+				t.Fatalf("Error creating function SDK: %v", err)
+			}
+
+			server := httptest.NewServer(funcSDK.Handler())
+			defer server.Close()
+
+			resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{}`))
+			if err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			defer resp.Body.Close()
+			var result struct {
+				Data sdk.Response `json:"data"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+			if diff := cmp.Diff(sdk.Response{"instance": name}, result.Data); diff != "" {
+				t.Errorf("Unexpected response: %s", diff)
+			}
+
+			ready, err := http.Get(server.URL + "/_/ready")
+			if err != nil {
+				t.Fatalf("Error getting readiness: %v", err)
+			}
+			ready.Body.Close()
+			if ready.StatusCode != http.StatusOK {
+				t.Errorf("Expected ready, got %d", ready.StatusCode)
+			}
+		})
+	}
+}
+
+func TestFunctionSDKRunMultipleInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 2)
+
+	for i := 0; i < 2; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Error creating listener: %v", err)
+		}
+		funcSDK, err := sdk.NewFunctionSDK(
+			sdk.WithListener(listener),
+			sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+				return sdk.Response{}, nil
+			}),
+		)
+		if err != nil {
+			t.Fatalf("Error creating function SDK: %v", err)
+		}
+		go func() {
+			runErr <- funcSDK.Run(ctx)
+		}()
+	}
+
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-runErr; err != nil {
+			t.Errorf("Error running function SDK: %v", err)
+		}
+	}
+}
+
+func TestFunctionSDKConcurrency(t *testing.T) {
+	unblock := make(chan struct{})
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithMaxConcurrentInvocations(1),
+		sdk.WithInvocationQueueTimeout(100*time.Millisecond),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			<-unblock
+			return sdk.Response{}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+	server := httptest.NewServer(funcSDK.Handler())
+	defer server.Close()
+
+	// saturate the function and check that it reports not ready and sheds load
+	blocked := make(chan error, 1)
+	go func() {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+		blocked <- err
+	}()
+
+	var ready sdk.ReadyResponse
+	for i := 0; i < 50; i++ {
+		resp, err := http.Get(server.URL + "/_/ready")
+		if err != nil {
+			t.Fatalf("Error getting readiness: %v", err)
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&ready)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if diff := cmp.Diff(sdk.ReadyResponse{Ready: false, NumConnections: 1, MaxConcurrency: 1}, ready); diff != "" {
+		t.Errorf("Unexpected readiness while saturated: %s", diff)
+	}
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	var rejected sdk.ErrFunction
+	_ = json.NewDecoder(resp.Body).Decode(&rejected)
+	resp.Body.Close()
+	if rejected.ErrCode != sdk.ErrCodeTransient {
+		t.Errorf("Expected transient error while saturated, got %+v", rejected)
+	}
+
+	close(unblock)
+	if err := <-blocked; err != nil {
+		t.Errorf("Error in blocking request: %v", err)
+	}
+}
+
+func TestFunctionSDKShutdown(t *testing.T) {
+	var (
+		logsMu sync.Mutex
+		logs   []byte
+	)
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("content")
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		chunk, _ := io.ReadAll(file)
+		logsMu.Lock()
+		defer logsMu.Unlock()
+		logs = append(logs, chunk...)
+	}))
+	defer engine.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating listener: %v", err)
+	}
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithListener(listener),
+		sdk.WithLogWriteTimeout(5*time.Second),
+		sdk.WithShutdownTimeout(1*time.Second),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			logger.Info("waiting for shutdown")
+			<-ctx.Done()
+			return nil, context.Cause(ctx)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- funcSDK.Run(ctx)
+	}()
+
+	url := fmt.Sprintf("http://%s", listener.Addr().String())
+	invoke := func() (sdk.ErrFunction, error) {
+		req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(`{}`))
+		if err != nil {
+			return sdk.ErrFunction{}, err
+		}
+		req.Header.Set(sdk.EngineAPIEndpointHeader, engine.URL)
+		req.Header.Set(sdk.ActivityFileUploadHeader, "/activity/draining/log")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return sdk.ErrFunction{}, err
+		}
+		defer resp.Body.Close()
+		var result sdk.ErrFunction
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		return result, err
+	}
+	readiness := func() (int, sdk.ReadyResponse) {
+		resp, err := http.Get(url + "/_/ready")
+		if err != nil {
+			t.Fatalf("Error getting readiness: %v", err)
+		}
+		defer resp.Body.Close()
+		var ready sdk.ReadyResponse
+		_ = json.NewDecoder(resp.Body).Decode(&ready)
+		return resp.StatusCode, ready
+	}
+
+	// shut down while an invocation is in flight: readiness must fail at once, new
+	// invocations are rejected and the in-flight one is cancelled with a known cause
+	draining := make(chan sdk.ErrFunction, 1)
+	go func() {
+		drained, err := invoke()
+		if err != nil {
+			t.Errorf("Error in draining request: %v", err)
+		}
+		draining <- drained
+	}()
+	for i := 0; i < 100; i++ {
+		if _, ready := readiness(); ready.NumConnections > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	if status, _ := readiness(); status != http.StatusServiceUnavailable {
+		t.Errorf("Expected readiness to fail during shutdown, got %d", status)
+	}
+	if rejected, err := invoke(); err != nil || rejected.ErrCode != sdk.ErrCodeTransient {
+		t.Errorf("Expected transient error during shutdown, got %+v, %v", rejected, err)
+	}
+
+	if drained := <-draining; drained.ErrCode != sdk.ErrCodeTransient || drained.Message != sdk.ErrShuttingDown.Error() {
+		t.Errorf("Expected in-flight invocation to be cancelled with a transient ErrShuttingDown, got %+v", drained)
+	}
+	if err := <-runErr; err == nil || !strings.Contains(err.Error(), "did not complete") {
+		t.Errorf("Expected shutdown error, got %v", err)
+	}
+
+	logsMu.Lock()
+	defer logsMu.Unlock()
+	if !strings.Contains(string(logs), "waiting for shutdown") {
+		t.Errorf("Expected logs of the cancelled invocation to be flushed")
+	}
+}
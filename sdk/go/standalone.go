@@ -0,0 +1,78 @@
+package sdk
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrExecTimeoutExceeded is the cause of the context cancellation of an invocation
+// that ran longer than the exec timeout set with WithExecTimeout.
+var ErrExecTimeoutExceeded = errors.New("function exec timeout exceeded")
+
+// defaultHealthFile is the lock file of-watchdog keeps while the function is
+// healthy, so container health checks such as `test -f /tmp/.lock` keep working
+// when the function runs without it.
+var defaultHealthFile = filepath.Join(os.TempDir(), ".lock")
+
+// startHealthReporting keeps the health file in standalone mode, rewriting it every
+// health interval, and returns a func that stops reporting and removes the file.
+func (f *FunctionSDK) startHealthReporting() func() {
+	if !f.standalone {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var tick <-chan time.Time
+		if f.healthInterval > 0 {
+			ticker := time.NewTicker(f.healthInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			f.updateHealthFile()
+			select {
+			case <-stop:
+				return
+			case <-tick:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		if err := os.Remove(f.healthFile); err != nil && !os.IsNotExist(err) {
+			f.logger.Warn("[entrypoint] Error removing health file", "error", err)
+		}
+	}
+}
+
+func (f *FunctionSDK) updateHealthFile() {
+	if f.draining.Load() {
+		_ = os.Remove(f.healthFile)
+		return
+	}
+	if err := os.WriteFile(f.healthFile, []byte(time.Now().UTC().Format(time.RFC3339)), 0o644); err != nil {
+		f.logger.Warn("[entrypoint] Error writing health file", "error", err)
+	}
+}
+
+// healthHandler answers the /_/health probe that of-watchdog would answer. As with
+// of-watchdog's ready_path, the function is healthy while its health file exists
+// and it is ready for invocations.
+func (f *FunctionSDK) healthHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := os.Stat(f.healthFile); err != nil || !f.ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK"))
+}
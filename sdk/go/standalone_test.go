@@ -0,0 +1,168 @@
+package sdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+)
+
+func TestStandalone(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating listener: %v", err)
+	}
+	healthFile := filepath.Join(t.TempDir(), ".lock")
+
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithListener(listener),
+		sdk.WithStandalone(true),
+		sdk.WithHealthFile(healthFile),
+		sdk.WithHealthInterval(20*time.Millisecond),
+		sdk.WithExecTimeout(100*time.Millisecond),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			<-ctx.Done()
+			return nil, context.Cause(ctx)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- funcSDK.Run(ctx)
+	}()
+
+	healthy := func() bool {
+		_, err := os.Stat(healthFile)
+		return err == nil
+	}
+	for i := 0; i < 50 && !healthy(); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !healthy() {
+		t.Fatalf("Expected health file to be written")
+	}
+
+	// the health file is rewritten every interval
+	if err := os.Remove(healthFile); err != nil {
+		t.Fatalf("Error removing health file: %v", err)
+	}
+	for i := 0; i < 50 && !healthy(); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !healthy() {
+		t.Errorf("Expected health file to be rewritten")
+	}
+
+	start := time.Now()
+	resp, err := http.Post(fmt.Sprintf("http://%s/", listener.Addr()), "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	var result map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	resp.Body.Close()
+	if result["message"] != sdk.ErrExecTimeoutExceeded.Error() {
+		t.Errorf("Expected exec timeout cause, got %v", result)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Exec timeout took %s", elapsed)
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Error running function SDK: %v", err)
+	}
+	if healthy() {
+		t.Errorf("Expected health file to be removed on shutdown")
+	}
+}
+
+func TestStandaloneHealth(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating listener: %v", err)
+	}
+	healthFile := filepath.Join(t.TempDir(), ".lock")
+
+	var invocations atomic.Int32
+	release := make(chan struct{})
+	funcSDK, err := sdk.NewFunctionSDK(
+		sdk.WithListener(listener),
+		sdk.WithStandalone(true),
+		sdk.WithHealthFile(healthFile),
+		sdk.WithMaxConcurrentInvocations(1),
+		sdk.WithHandler(func(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Response, error) {
+			invocations.Add(1)
+			<-release
+			return sdk.Response{}, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating function SDK: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- funcSDK.Run(ctx)
+	}()
+
+	health := func() int {
+		resp, err := http.Get(fmt.Sprintf("http://%s/_/health", listener.Addr()))
+		if err != nil {
+			t.Fatalf("Error probing health: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	status := health()
+	for i := 0; i < 50 && status != http.StatusOK; i++ {
+		time.Sleep(20 * time.Millisecond)
+		status = health()
+	}
+	if status != http.StatusOK {
+		t.Fatalf("Expected the function to be healthy, got %d", status)
+	}
+
+	// a saturated function is not healthy, as with of-watchdog's ready_path
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := http.Post(fmt.Sprintf("http://%s/", listener.Addr()), "application/json", strings.NewReader(`{}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	for i := 0; i < 50 && invocations.Load() == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if status := health(); status != http.StatusServiceUnavailable {
+		t.Errorf("Expected a saturated function to be unhealthy, got %d", status)
+	}
+	close(release)
+	<-done
+
+	if got := invocations.Load(); got != 1 {
+		t.Errorf("Expected health probes not to invoke the handler, got %d invocations", got)
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Error running function SDK: %v", err)
+	}
+}
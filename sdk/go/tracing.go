@@ -0,0 +1,116 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name used for spans created by the SDK.
+const TracerName = "github.com/RafaySystems/function-templates/sdk/go"
+
+// TraceExporter selects where spans created by the SDK are exported.
+type TraceExporter string
+
+const (
+	// TraceExporterNone exports spans through the global tracer provider.
+	TraceExporterNone TraceExporter = ""
+	// TraceExporterOTLP exports spans over OTLP/HTTP. The endpoint is an URL such as
+	// http://collector:4318; when empty the OTEL_EXPORTER_OTLP_* env vars are used.
+	TraceExporterOTLP TraceExporter = "otlp"
+	// TraceExporterFile writes spans as JSON to the file at endpoint, or stdout when empty.
+	TraceExporterFile TraceExporter = "file"
+)
+
+// Propagator extracts and injects W3C trace context and baggage.
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// newTracerProvider builds a tracer provider for the given exporter. The returned
+// shutdown func flushes pending spans and is a no-op when nothing was created.
+func newTracerProvider(exporter TraceExporter, endpoint string) (trace.TracerProvider, func(context.Context) error, error) {
+	noShutdown := func(context.Context) error { return nil }
+
+	var spanExporter sdktrace.SpanExporter
+	closeFile := noShutdown
+	switch exporter {
+	case TraceExporterNone:
+		return otel.GetTracerProvider(), noShutdown, nil
+	case TraceExporterOTLP:
+		var opts []otlptracehttp.Option
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		exp, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create otlp trace exporter: %w", err)
+		}
+		spanExporter = exp
+	case TraceExporterFile:
+		out := os.Stdout
+		if endpoint != "" {
+			file, err := os.OpenFile(endpoint, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+			}
+			out = file
+			closeFile = func(context.Context) error { return file.Close() }
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(out))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create file trace exporter: %w", err)
+		}
+		spanExporter = exp
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName()))),
+	)
+
+	return tp, func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if cerr := closeFile(ctx); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+}
+
+func serviceName() string {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+	return "function"
+}
+
+// StartSpan starts a child span of the span in ctx using the same tracer provider,
+// so spans created inside a handler end up in the invocation's trace.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(TracerName)
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan records err on span, if any, and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
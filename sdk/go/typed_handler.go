@@ -0,0 +1,111 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// TypedRequest carries the decoded request body together with its metadata.
+type TypedRequest[In any] struct {
+	Metadata Metadata
+	Input    In
+}
+
+// TypedHandlerFunc is a handler operating on Go types instead of Request and Response maps.
+type TypedHandlerFunc[In, Out any] func(ctx context.Context, logger Logger, req TypedRequest[In]) (Out, error)
+
+// TypedHandler adapts fn to a Handler. The request body is decoded into In and the
+// returned Out is encoded as the response data, so Out must encode to a JSON object.
+// Fields that cannot be decoded are reported as a validation error.
+func TypedHandler[In, Out any](fn TypedHandlerFunc[In, Out]) Handler {
+	return func(ctx context.Context, logger Logger, req Request) (Response, error) {
+		in, err := decodeInput[In](req)
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := fn(ctx, logger, TypedRequest[In]{Metadata: req.Metadata(), Input: in})
+		if err != nil {
+			return nil, err
+		}
+
+		return encodeOutput(out)
+	}
+}
+
+func decodeInput[In any](req Request) (In, error) {
+	var in In
+
+	body := make(map[string]json.RawMessage, len(req))
+	for key, val := range req {
+		if key == "metadata" {
+			continue
+		}
+		raw, err := json.Marshal(val)
+		if err != nil {
+			return in, NewErrFailed(fmt.Sprintf("failed to encode request field %s: %s", key, err))
+		}
+		body[key] = raw
+	}
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return in, NewErrFailed(fmt.Sprintf("failed to encode request: %s", err))
+	}
+	if err = json.Unmarshal(raw, &in); err == nil {
+		return in, nil
+	}
+
+	// decode each field on its own so that every offending field is reported
+	// rather than just the first one
+	keys := make([]string, 0, len(body))
+	for key := range body {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var violations []FieldViolation
+	for _, key := range keys {
+		raw, _ := json.Marshal(map[string]json.RawMessage{key: body[key]})
+		var partial In
+		if ferr := json.Unmarshal(raw, &partial); ferr != nil {
+			violations = append(violations, fieldViolation(key, ferr))
+		}
+	}
+	if len(violations) == 0 {
+		violations = append(violations, FieldViolation{Message: err.Error()})
+	}
+
+	return in, NewErrValidation("invalid input", violations)
+}
+
+func fieldViolation(key string, err error) FieldViolation {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := key
+		if typeErr.Field != "" {
+			field = typeErr.Field
+		}
+		return FieldViolation{
+			Field:   field,
+			Message: fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value),
+		}
+	}
+	return FieldViolation{Field: key, Message: err.Error()}
+}
+
+func encodeOutput[Out any](out Out) (Response, error) {
+	raw, err := json.Marshal(out)
+	if err != nil {
+		return nil, NewErrFailed(fmt.Sprintf("failed to encode response: %s", err))
+	}
+
+	var resp Response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, NewErrFailed("response must encode to a JSON object")
+	}
+	return resp, nil
+}
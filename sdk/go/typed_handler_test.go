@@ -0,0 +1,91 @@
+package sdk_test
+
+import (
+	"context"
+	"testing"
+
+	sdk "github.com/RafaySystems/function-templates/sdk/go"
+	"github.com/google/go-cmp/cmp"
+)
+
+type typedInput struct {
+	Namespace string         `json:"namespace"`
+	Replicas  int            `json:"replicas"`
+	Values    map[string]any `json:"values"`
+	Chart     struct {
+		Version string `json:"version"`
+	} `json:"chart"`
+}
+
+type typedOutput struct {
+	Namespace string `json:"namespace"`
+	Activity  string `json:"activity"`
+}
+
+func TestTypedHandler(t *testing.T) {
+	handler := sdk.TypedHandler(func(ctx context.Context, logger sdk.Logger, req sdk.TypedRequest[typedInput]) (typedOutput, error) {
+		return typedOutput{Namespace: req.Input.Namespace, Activity: req.Metadata.ActivityID}, nil
+	})
+
+	t.Run("decode and encode", func(t *testing.T) {
+		req := sdk.Request{
+			"namespace": "default",
+			"replicas":  float64(2),
+			"metadata":  map[string]string{"activityID": "activity1"},
+		}
+		resp, err := handler(context.Background(), nil, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := sdk.Response{"namespace": "default", "activity": "activity1"}
+		if diff := cmp.Diff(want, resp); diff != "" {
+			t.Errorf("unexpected response: %s", diff)
+		}
+	})
+
+	t.Run("missing metadata", func(t *testing.T) {
+		resp, err := handler(context.Background(), nil, sdk.Request{"namespace": "default"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp["activity"] != "" {
+			t.Errorf("expected empty activity, got %v", resp["activity"])
+		}
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		req := sdk.Request{
+			"namespace": 1,
+			"replicas":  "two",
+			"chart":     map[string]any{"version": true},
+		}
+		_, err := handler(context.Background(), nil, req)
+		if !sdk.IsErrValidation(err) {
+			t.Fatalf("expected validation error, got %v", err)
+		}
+		errFunc, ok := sdk.AsErrFunction(err)
+		if !ok {
+			t.Fatalf("expected ErrFunction, got %v", err)
+		}
+		if errFunc.ErrCode != sdk.ErrCodeFailed {
+			t.Errorf("expected ErrCodeFailed, got %d", errFunc.ErrCode)
+		}
+		var fields []string
+		for _, v := range errFunc.Data["violations"].([]sdk.FieldViolation) {
+			fields = append(fields, v.Field)
+		}
+		if diff := cmp.Diff([]string{"chart.version", "namespace", "replicas"}, fields); diff != "" {
+			t.Errorf("unexpected violations: %s", diff)
+		}
+	})
+}
+
+func TestTypedHandlerNonObjectOutput(t *testing.T) {
+	handler := sdk.TypedHandler(func(ctx context.Context, logger sdk.Logger, req sdk.TypedRequest[typedInput]) (string, error) {
+		return "not an object", nil
+	})
+	_, err := handler(context.Background(), nil, sdk.Request{})
+	if !sdk.IsErrFailed(err) {
+		t.Errorf("expected failed error, got %v", err)
+	}
+}
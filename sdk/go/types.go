@@ -17,8 +17,10 @@ type Logger interface {
 
 type Object map[string]any
 
+// MetaString returns the metadata value for key, or "" when the request carries no
+// metadata.
 func (r Object) MetaString(key string) string {
-	return r["metadata"].(map[string]string)[key]
+	return metadataMap(r)[key]
 }
 
 type (
@@ -38,6 +40,11 @@ type FunctionHandler interface {
 
 type Handler func(ctx context.Context, logger Logger, req Request) (Response, error)
 
+// Middleware wraps a Handler to add cross-cutting behaviour such as auth checks,
+// input redaction or timing. A middleware may short-circuit the chain by returning
+// an error (typically an ErrFunction) without calling next.
+type Middleware func(next Handler) Handler
+
 const (
 	ActivityIDHeader         = "X-Activity-ID"
 	EnvironmentIDHeader      = "X-Environment-ID"
@@ -52,11 +59,17 @@ const (
 	EventSourceHeader        = "X-Event-Source"
 	EventSourceNameHeader    = "X-Event-Source-Name"
 	EventTypeHeader          = "X-Event-Type"
+	ActivityDeadlineHeader   = "X-Activity-Deadline"
+	ActivityTimeoutHeader    = "X-Activity-Timeout"
+	ActivityHeartbeatHeader  = "X-Activity-Heartbeat"
 )
 
 type ReadyResponse struct {
-	Ready          bool  `json:"ready"`
+	Ready bool `json:"ready"`
+	// NumConnections is the number of invocations currently in flight.
 	NumConnections int32 `json:"num_connections"`
+	// MaxConcurrency is the concurrency limit, zero when unlimited.
+	MaxConcurrency int32 `json:"max_concurrency,omitempty"`
 }
 
 // EventType represents the type of event, commonly used in event-driven systems for categorization or processing.
@@ -54,8 +54,7 @@ func Handle(ctx context.Context, logger sdk.Logger, req sdk.Request) (sdk.Respon
 	}
 
 	// if no state store info, return early
-	metadata, ok := req["metadata"].(map[string]any)
-	if !ok || metadata["stateStoreUrl"] == "" {
+	if req.Metadata().StateStoreURL == "" {
 		return sdk.Response(resp), nil
 	}
 
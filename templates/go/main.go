@@ -5,8 +5,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"strconv"
-	"time"
 
 	"handler/function"
 
@@ -15,42 +13,19 @@ import (
 	"github.com/RafaySystems/envmgr-pkgs/signals"
 )
 
-const defaultTimeout = 10 * time.Second
-
 func main() {
-	readTimeout := parseIntOrDurationValue(os.Getenv("read_timeout"), defaultTimeout)
-	writeTimeout := parseIntOrDurationValue(os.Getenv("write_timeout"), defaultTimeout)
-	skipTLSVerify := os.Getenv("skip_tls_verify") == "true"
-
-	functionSDK, err := sdk.NewFunctionSDK(
-		sdk.WithReadTimeout(readTimeout),
-		sdk.WithWriteTimeout(writeTimeout),
-		sdk.WithLogFlushRate(1*time.Second),
-		sdk.WithLogWriteTimeout(10*time.Second),
-		sdk.WithServerSkipTLSVerify(skipTLSVerify),
-		sdk.WithPort(8082),
-		sdk.WithHandler(function.Handle))
+	// configuration such as read_timeout, write_timeout or log_level is read from
+	// the environment, see the SDK README for the full list
+	functionSDK, err := sdk.NewFunctionSDKFromEnv(function.Handle)
 	if err != nil {
 		fmt.Println("Error creating function SDK: ", err)
-		return
+		os.Exit(1)
 	}
 
 	ctx := signals.SetupSignalHandler()
 
-	functionSDK.Run(ctx)
-}
-
-func parseIntOrDurationValue(val string, fallback time.Duration) time.Duration {
-	if len(val) > 0 {
-		parsedVal, parseErr := strconv.Atoi(val)
-		if parseErr == nil && parsedVal >= 0 {
-			return time.Duration(parsedVal) * time.Second
-		}
-	}
-
-	duration, durationErr := time.ParseDuration(val)
-	if durationErr != nil {
-		return fallback
+	if err := functionSDK.Run(ctx); err != nil {
+		fmt.Println("Error running function SDK: ", err)
+		os.Exit(1)
 	}
-	return duration
 }